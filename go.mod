@@ -10,8 +10,10 @@ require (
 	github.com/arsham/rainbow v1.1.1
 	github.com/go-test/deep v1.0.7
 	github.com/hinshun/vt10x v0.0.0-20180616224451-1954e6464174
+	github.com/mattn/go-isatty v0.0.8
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.1.3
 	github.com/stretchr/testify v1.7.0
+	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
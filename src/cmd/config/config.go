@@ -0,0 +1,13 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "config",
+		Short: "Manages context files",
+	}
+}
@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TwinProduction/go-color"
+	"github.com/spf13/cobra"
+
+	yey "github.com/silphid/yey/src/internal"
+)
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate [file]",
+		Short: "Rewrites context file to latest version, preserving comments",
+		Long:  "Rewrites given context file (or the one that would be used from current directory) to latest version, preserving comments",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return run(args)
+		},
+	}
+}
+
+func run(args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		var err error
+		path, err = yey.FindContextFilePath()
+		if err != nil {
+			return err
+		}
+	}
+
+	migrated, err := yey.MigrateContextFile(path)
+	if err != nil {
+		return err
+	}
+	if yey.IsDryRun {
+		return nil
+	}
+	if !migrated {
+		fmt.Fprintln(os.Stderr, color.Ize(color.Green, fmt.Sprintf("%s already up-to-date", path)))
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, color.Ize(color.Green, fmt.Sprintf("%s migrated to latest version", path)))
+	return nil
+}
@@ -0,0 +1,30 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	yey "github.com/silphid/yey/src/internal"
+)
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Outputs JSON Schema of context files, for editor validation and completion",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return run()
+		},
+	}
+}
+
+func run() error {
+	schema, err := yey.JSONSchema()
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(schema))
+	return nil
+}
@@ -0,0 +1,51 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/TwinProduction/go-color"
+	"github.com/spf13/cobra"
+
+	yey "github.com/silphid/yey/src/internal"
+)
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Validates context file structure",
+		Long:  "Validates structure of given context file (or the one that would be used from current directory), reporting problems with their file:line:column locations",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return run(args)
+		},
+	}
+}
+
+func run(args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		var err error
+		path, err = yey.FindContextFilePath()
+		if err != nil {
+			return err
+		}
+	}
+
+	diagnostics, err := yey.ValidateContextFile(path)
+	if err != nil {
+		return err
+	}
+	if len(diagnostics) > 0 {
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic)
+		}
+		return fmt.Errorf("found %d problem(s) in %s", len(diagnostics), path)
+	}
+
+	fmt.Fprintln(os.Stderr, color.Ize(color.Green, fmt.Sprintf("%s is valid", path)))
+	return nil
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
+	"text/tabwriter"
 
 	"github.com/TwinProduction/go-color"
 	"github.com/spf13/cobra"
@@ -13,6 +13,8 @@ import (
 	"github.com/silphid/yey/src/internal/docker"
 )
 
+const timeFormat = "2006-01-02 15:04:05"
+
 type Options struct {
 	All bool
 }
@@ -36,22 +38,29 @@ func New() *cobra.Command {
 }
 
 func run(ctx context.Context, options Options) error {
-	containers, err := docker.ListContainers(ctx, true)
+	// Project is only relevant when not listing all containers
+	var contexts yey.Contexts
+	if !options.All {
+		var err error
+		contexts, err = yey.LoadContexts()
+		if err != nil {
+			return err
+		}
+	}
+	if err := docker.UseRuntime(contexts.Runtime); err != nil {
+		return err
+	}
+
+	containers, err := docker.ListContainersWithLegacy(ctx, true, contexts.Path)
 	if err != nil {
 		return err
 	}
 	totalCount := len(containers)
 
 	if !options.All {
-		contexts, err := yey.LoadContexts()
-		if err != nil {
-			return err
-		}
-		prefix := yey.ContainerPathPrefix(contexts.Path)
-
-		var filteredContainers []string
+		var filteredContainers []docker.Container
 		for _, container := range containers {
-			if strings.HasPrefix(container, prefix) {
+			if container.Path == contexts.Path {
 				filteredContainers = append(filteredContainers, container)
 			}
 		}
@@ -66,6 +75,17 @@ func run(ctx context.Context, options Options) error {
 		fmt.Fprintln(os.Stderr, color.Ize(color.Green, "no yey containers found"))
 		return nil
 	}
-	fmt.Println(strings.Join(containers, "\n"))
-	return nil
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, container := range containers {
+		created := ""
+		if !container.Created.IsZero() {
+			created = container.Created.Local().Format(timeFormat)
+		}
+		if options.All {
+			fmt.Fprintf(w, "%s\t", container.Path)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", container.ContextName(), created, container.Name)
+	}
+	return w.Flush()
 }
@@ -21,22 +21,22 @@ func New() *cobra.Command {
 }
 
 func run(names []string) error {
-	contexts, err := yey.LoadContexts()
+	contexts, err := yey.LoadContextsWithChoices()
 	if err != nil {
 		return err
 	}
 
-	lastNames, err := cmd.LoadLastNames()
+	lastNames, err := cmd.LoadLastNames(contexts.Path)
 	if err != nil {
 		return err
 	}
 
-	names, err = cmd.GetOrPromptContexts(contexts.Context, names, lastNames)
+	names, err = cmd.GetOrPromptContexts(contexts, names, lastNames)
 	if err != nil {
 		return err
 	}
 
-	err = cmd.SaveLastNames(names)
+	err = cmd.SaveLastNames(contexts.Path, names)
 	if err != nil {
 		return err
 	}
@@ -2,34 +2,71 @@ package contexts
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/TwinProduction/go-color"
 	yey "github.com/silphid/yey/src/internal"
 
 	"github.com/spf13/cobra"
 )
 
+const defaultMarker = "*"
+
 // New creates a cobra command
 func New() *cobra.Command {
-	return &cobra.Command{
+	var options struct {
+		long bool
+	}
+	c := &cobra.Command{
 		Use:   "contexts",
 		Short: "Lists available contexts",
 		Args:  cobra.NoArgs,
 		RunE: func(_ *cobra.Command, args []string) error {
-			return run()
+			return run(options.long)
 		},
 	}
+	c.Flags().BoolVarP(&options.long, "long", "l", false, "show description of each choice")
+	return c
 }
 
-func run() error {
-	contexts, err := yey.LoadContexts()
+func run(long bool) error {
+	contexts, err := yey.LoadContextsWithChoices()
 	if err != nil {
 		return err
 	}
 
 	combos := contexts.GetCombos()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	hasDefaults := false
 	for _, combo := range combos {
-		fmt.Println(strings.Join(combo, " "))
+		defaults := contexts.GetDefaults(combo)
+		names := make([]string, 0, len(combo))
+		for i, name := range combo {
+			if i < len(defaults) && defaults[i] {
+				name += defaultMarker
+				hasDefaults = true
+			}
+			names = append(names, name)
+		}
+		if !long {
+			fmt.Fprintln(w, strings.Join(names, " "))
+			continue
+		}
+		var descriptions []string
+		for _, description := range contexts.GetDescriptions(combo) {
+			if description != "" {
+				descriptions = append(descriptions, description)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\n", strings.Join(names, " "), strings.Join(descriptions, " / "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if hasDefaults {
+		fmt.Fprintln(os.Stderr, color.Ize(color.Green, fmt.Sprintf("(%s: default choice)", defaultMarker)))
 	}
 	return nil
 }
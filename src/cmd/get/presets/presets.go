@@ -0,0 +1,34 @@
+package presets
+
+import (
+	"fmt"
+	"strings"
+
+	yey "github.com/silphid/yey/src/internal"
+
+	"github.com/spf13/cobra"
+)
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "presets",
+		Short: "Lists available presets",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return run()
+		},
+	}
+}
+
+func run() error {
+	contexts, err := yey.LoadContexts()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range contexts.GetPresetNames() {
+		fmt.Printf("%s: %s\n", name, strings.Join(contexts.Presets[name], " "))
+	}
+	return nil
+}
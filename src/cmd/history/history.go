@@ -0,0 +1,66 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/silphid/yey/src/cmd/run"
+	yey "github.com/silphid/yey/src/internal"
+
+	"github.com/spf13/cobra"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+// New creates a cobra command
+func New() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history [index]",
+		Short: "Lists context names previously selected in current project, or runs container using given entry",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			contexts, err := yey.LoadContexts()
+			if err != nil {
+				return err
+			}
+			history, err := yey.LoadHistory()
+			if err != nil {
+				return err
+			}
+			entries := history.GetEntries(contexts.Path)
+
+			if len(args) == 0 {
+				return list(entries)
+			}
+
+			index, err := strconv.Atoi(args[0])
+			if err != nil || index < 1 || index > len(entries) {
+				return fmt.Errorf("invalid history index %q: expecting number between 1 and %d", args[0], len(entries))
+			}
+			return run.Run(cmd.Context(), entries[index-1].Names, run.Options{})
+		},
+	}
+}
+
+func list(entries []yey.HistoryEntry) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, entry := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, entry.Time.Local().Format(timeFormat), formatNames(entry.Names))
+	}
+	return w.Flush()
+}
+
+// formatNames joins given names with spaces, quoting those that contain spaces
+func formatNames(names []string) string {
+	formatted := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.ContainsAny(name, " \t") {
+			name = strconv.Quote(name)
+		}
+		formatted = append(formatted, name)
+	}
+	return strings.Join(formatted, " ")
+}
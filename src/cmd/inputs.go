@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	yey "github.com/silphid/yey/src/internal"
+)
+
+// ParseInputArgs parses given `key=value` input arguments into a map
+func ParseInputArgs(args []string) (map[string]string, error) {
+	values := make(map[string]string, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid input %q: expecting key=value", arg)
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, nil
+}
+
+// GetOrPromptInputs returns the values of given inputs, either from given argument values or,
+// as needed, prompting user for them
+func GetOrPromptInputs(inputs []yey.Input, argValues map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(inputs))
+	for name, value := range argValues {
+		values[name] = value
+	}
+
+	for _, input := range inputs {
+		if _, ok := values[input.Name]; ok {
+			continue
+		}
+		if input.Default != "" && (yey.IsAssumeYes || !IsInteractive()) {
+			// use default without prompting
+			yey.Log("using default input %s", input.Name)
+			continue
+		}
+		if !IsInteractive() {
+			return nil, fmt.Errorf("cannot prompt for input %q in non-interactive mode and no default was specified", input.Name)
+		}
+
+		value, err := promptInput(input)
+		if err != nil {
+			return nil, err
+		}
+		values[input.Name] = value
+	}
+	return values, nil
+}
+
+// promptInput prompts user for value of given input, masking it if secret
+func promptInput(input yey.Input) (string, error) {
+	var prompt survey.Prompt
+	if input.Secret {
+		prompt = &survey.Password{Message: input.GetPrompt()}
+	} else {
+		prompt = &survey.Input{Message: input.GetPrompt(), Default: input.Default}
+	}
+
+	validator := func(answer interface{}) error {
+		value, _ := answer.(string)
+		if value == "" {
+			if input.Default != "" {
+				return nil
+			}
+			if input.Pattern == "" {
+				return errors.New("value is required")
+			}
+		}
+		return input.Validate(value)
+	}
+
+	var value string
+	if err := survey.AskOne(prompt, &value, survey.WithValidator(validator)); err != nil {
+		return "", err
+	}
+	if value == "" {
+		value = input.Default
+	}
+	return value, nil
+}
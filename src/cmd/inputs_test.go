@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInputArgs(t *testing.T) {
+	values, err := ParseInputArgs([]string{"ticket=INC-42", "query=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ticket": "INC-42", "query": "a=b", "empty": ""}, values)
+
+	_, err = ParseInputArgs([]string{"ticket"})
+	assert.EqualError(t, err, `invalid input "ticket": expecting key=value`)
+}
+
+func TestGetOrPromptInputsNonInteractive(t *testing.T) {
+	inputs := []yey.Input{
+		{Name: "ticket"},
+		{Name: "namespace", Default: "default"},
+	}
+
+	values, err := GetOrPromptInputs(inputs, map[string]string{"ticket": "INC-42"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"ticket": "INC-42"}, values)
+
+	_, err = GetOrPromptInputs(inputs, nil)
+	assert.EqualError(t, err, `cannot prompt for input "ticket" in non-interactive mode and no default was specified`)
+}
@@ -1,48 +1,25 @@
 package cmd
 
 import (
-	"errors"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"strings"
+	"time"
 
-	"github.com/mitchellh/go-homedir"
+	yey "github.com/silphid/yey/src/internal"
 )
 
-func getLastNamesFilePath() (string, error) {
-	return homedir.Expand("~/.yeylast")
+// SaveLastNames records given context names in the history of RC file with given path
+func SaveLastNames(path string, names []string) error {
+	return yey.UpdateHistory(path, names, time.Now())
 }
 
-// SaveLastNames saves to home dir the context names that were last selected by user
-func SaveLastNames(names []string) error {
-	file, err := getLastNamesFilePath()
+// LoadLastNames loads the context names that were last selected by user for RC file with given path,
+// falling back to those last selected with older versions of yey when project has no history yet
+func LoadLastNames(path string) ([]string, error) {
+	history, err := yey.LoadHistory()
 	if err != nil {
-		return fmt.Errorf("failed to determine last context names file path: %w", err)
+		return nil, err
 	}
-
-	err = ioutil.WriteFile(file, ([]byte)(strings.Join(names, " ")), 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write last context names file: %w", err)
+	if names := history.GetLastNames(path); names != nil {
+		return names, nil
 	}
-
-	return nil
-}
-
-// LoadLastNames loads from home dir the context names that were last selected by user
-func LoadLastNames() ([]string, error) {
-	file, err := getLastNamesFilePath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to determine last context names file path: %w", err)
-	}
-
-	text, err := ioutil.ReadFile(file)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return []string{}, nil
-		}
-		return nil, fmt.Errorf("failed to read last context names file: %w", err)
-	}
-
-	return strings.Split((string)(text), " "), nil
+	return yey.LoadLegacyLastNames()
 }
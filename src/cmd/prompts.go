@@ -2,17 +2,29 @@ package cmd
 
 import (
 	"fmt"
-	"sort"
+	"os"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/TwinProduction/go-color"
+	"github.com/mattn/go-isatty"
 	yey "github.com/silphid/yey/src/internal"
+	"github.com/silphid/yey/src/internal/docker"
 )
 
-// GetOrPromptContexts parses given value into context name and variant and, as needed, prompt user for those values
-func GetOrPromptContexts(context yey.Context, argNames []string, lastNames []string) ([]string, error) {
-	names, remainingArgNames, _, err := getOrPromptContextsRecursively(context, argNames, lastNames)
+// GetOrPromptContexts parses given value into context name and variant and, as needed, prompt user for those values.
+// A single preset name can also be passed as argument, in which case it gets expanded into its context names.
+func GetOrPromptContexts(contexts yey.Contexts, argNames []string, lastNames []string) ([]string, error) {
+	if len(argNames) == 1 {
+		if presetNames, ok := contexts.Presets[argNames[0]]; ok {
+			yey.Log("using preset %s: %s", argNames[0], strings.Join(presetNames, " "))
+			argNames = presetNames
+		}
+	}
+
+	context := contexts.Context
+	validCombos := context.GetCombos()
+	names, remainingArgNames, _, err := getOrPromptContextsRecursively(context, argNames, lastNames, validCombos, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -22,7 +34,9 @@ func GetOrPromptContexts(context yey.Context, argNames []string, lastNames []str
 	return names, nil
 }
 
-func getOrPromptContextsRecursively(context yey.Context, argNames []string, lastNames []string) ([]string, []string, []string, error) {
+// getOrPromptContextsRecursively determines context names for all variations of given context, where validCombos
+// are the combinations allowed by constraints and prefix is the list of names already selected by parent calls
+func getOrPromptContextsRecursively(context yey.Context, argNames []string, lastNames []string, validCombos [][]string, prefix []string) ([]string, []string, []string, error) {
 	if len(argNames) == 1 && argNames[0] == "-" {
 		return lastNames, nil, nil, nil
 	}
@@ -33,21 +47,28 @@ func getOrPromptContextsRecursively(context yey.Context, argNames []string, last
 		var selectedName string
 		if len(argNames) > 0 {
 			// use name passed as argument
-			selectedName = argNames[0]
+			var err error
+			selectedName, err = matchChoice(variation, argNames[0])
+			if err != nil {
+				return nil, nil, nil, err
+			}
 			argNames = argNames[1:]
+		} else if variation.Default != "" && (yey.IsAssumeYes || !IsInteractive()) {
+			// use default without prompting
+			selectedName = variation.Default
+			yey.Log("using default %s: %s", variation.Name, selectedName)
+		} else if !IsInteractive() {
+			return nil, nil, nil, fmt.Errorf("cannot prompt for %s in non-interactive mode and no default was specified", variation.Name)
 		} else {
 			// prompt for name
-			prompt := &survey.Select{
-				Message: fmt.Sprintf("Select %s", variation.Name),
-			}
-			for k := range variation.Contexts {
-				prompt.Options = append(prompt.Options, k)
-			}
-			sort.Strings(prompt.Options)
+			currentPrefix := append(append([]string(nil), prefix...), selectedNames...)
+			lastName := ""
 			if len(lastNames) > 0 {
-				prompt.Default = lastNames[0]
+				lastName = lastNames[0]
 			}
-			if err := survey.AskOne(prompt, &selectedName); err != nil {
+			var err error
+			selectedName, err = promptChoice(variation, currentPrefix, lastName, validCombos)
+			if err != nil {
 				return nil, nil, nil, err
 			}
 		}
@@ -57,17 +78,23 @@ func getOrPromptContextsRecursively(context yey.Context, argNames []string, last
 			lastNames = lastNames[1:]
 		}
 
+		// Normalize name (multi-select choices in declaration order)
+		selection, err := variation.ParseSelection(selectedName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		selectedName = strings.Join(selection, yey.MultiSeparator)
 		selectedNames = append(selectedNames, selectedName)
 
-		// Prompt recursively for variation's own child variations
-		selectedContext, ok := variation.Contexts[selectedName]
-		if !ok {
-			return nil, nil, nil, fmt.Errorf("variation %q has no context %q", variation.Name, selectedName)
-		}
-		if len(selectedContext.Variations) > 0 {
+		// Prompt recursively for selected contexts' own child variations
+		for _, choice := range selection {
+			selectedContext := variation.Contexts[choice]
+			if len(selectedContext.Variations) == 0 {
+				continue
+			}
 			var childNames []string
-			var err error
-			childNames, argNames, lastNames, err = getOrPromptContextsRecursively(selectedContext, argNames, lastNames)
+			childPrefix := append(append([]string(nil), prefix...), selectedNames...)
+			childNames, argNames, lastNames, err = getOrPromptContextsRecursively(selectedContext, argNames, lastNames, validCombos, childPrefix)
 			if err != nil {
 				return nil, nil, nil, err
 			}
@@ -78,6 +105,193 @@ func getOrPromptContextsRecursively(context yey.Context, argNames []string, last
 	return selectedNames, argNames, lastNames, nil
 }
 
+// promptChoice prompts user to select one choice of given variation (or multiple ones for multi-select
+// variations), among those allowed by constraints
+func promptChoice(variation yey.Variation, prefix []string, lastName string, validCombos [][]string) (string, error) {
+	var options, labels []string
+	for _, k := range variation.GetNames() {
+		// Skip choices that would only lead to combinations forbidden by constraints
+		if hasComboWithPrefix(validCombos, append(prefix, k)) {
+			options = append(options, k)
+			labels = append(labels, formatChoice(k, variation.Contexts[k].Description))
+		}
+	}
+	if len(options) == 0 {
+		return "", fmt.Errorf("no valid choice for %s with %q", variation.Name, strings.Join(prefix, " "))
+	}
+
+	// Determine default choices from last selection, if still valid, or from variation's default
+	defaultNames := strings.Split(variation.Default, yey.MultiSeparator)
+	if lastName != "" {
+		lastNames := strings.Split(lastName, yey.MultiSeparator)
+		if areStringsInStrings(lastNames, options) && (variation.Multi || len(lastNames) == 1) {
+			defaultNames = lastNames
+		}
+	}
+	var defaultLabels []string
+	for i, option := range options {
+		if stringIsInStrings(option, defaultNames) {
+			defaultLabels = append(defaultLabels, labels[i])
+		}
+	}
+
+	message := fmt.Sprintf("Select %s", variation.Name)
+	if !variation.Multi {
+		prompt := &survey.Select{Message: message, Options: labels}
+		if len(defaultLabels) > 0 {
+			prompt.Default = defaultLabels[0]
+		}
+		var selectedIndex int
+		if err := survey.AskOne(prompt, &selectedIndex); err != nil {
+			return "", err
+		}
+		return options[selectedIndex], nil
+	}
+
+	prompt := &survey.MultiSelect{Message: message, Options: labels, Default: defaultLabels}
+	var selectedIndices []int
+	if err := survey.AskOne(prompt, &selectedIndices, survey.WithValidator(survey.Required)); err != nil {
+		return "", err
+	}
+	var selected []string
+	for _, selectedIndex := range selectedIndices {
+		selected = append(selected, options[selectedIndex])
+	}
+	return strings.Join(selected, yey.MultiSeparator), nil
+}
+
+// matchChoice returns the name of the choice of given variation matching given argument, which can
+// also be a case-insensitive and unambiguous prefix of choice name, unless in exact mode. For multi-select
+// variations, each of the combined names is matched individually. Ambiguous matches are resolved by
+// prompting user, when possible.
+func matchChoice(variation yey.Variation, arg string) (string, error) {
+	if yey.IsExact {
+		return arg, nil
+	}
+	if !variation.Multi {
+		return matchSingleChoice(variation, arg)
+	}
+	parts := strings.Split(arg, yey.MultiSeparator)
+	for i, part := range parts {
+		var err error
+		if parts[i], err = matchSingleChoice(variation, part); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(parts, yey.MultiSeparator), nil
+}
+
+func matchSingleChoice(variation yey.Variation, arg string) (string, error) {
+	candidates := findChoices(variation.GetNames(), arg)
+	switch {
+	case len(candidates) == 0:
+		// Let caller report unknown name
+		return arg, nil
+	case len(candidates) == 1:
+		if candidates[0] != arg {
+			yey.Log("matched %s %q to %q", variation.Name, arg, candidates[0])
+		}
+		return candidates[0], nil
+	case !IsInteractive():
+		return "", fmt.Errorf("ambiguous %s %q: could be any of %s", variation.Name, arg, strings.Join(candidates, ", "))
+	}
+
+	prompt := &survey.Select{
+		Message: fmt.Sprintf("Select %s matching %q", variation.Name, arg),
+	}
+	for _, candidate := range candidates {
+		prompt.Options = append(prompt.Options, formatChoice(candidate, variation.Contexts[candidate].Description))
+	}
+	var selectedIndex int
+	if err := survey.AskOne(prompt, &selectedIndex); err != nil {
+		return "", err
+	}
+	return candidates[selectedIndex], nil
+}
+
+// findChoices returns the names matching given argument, by order of preference: exact match,
+// case-insensitive match or case-insensitive prefix matches
+func findChoices(names []string, arg string) []string {
+	if arg == "" {
+		return nil
+	}
+	if stringIsInStrings(arg, names) {
+		return []string{arg}
+	}
+	var equal, prefixed []string
+	lower := strings.ToLower(arg)
+	for _, name := range names {
+		if strings.ToLower(name) == lower {
+			equal = append(equal, name)
+		} else if strings.HasPrefix(strings.ToLower(name), lower) {
+			prefixed = append(prefixed, name)
+		}
+	}
+	if len(equal) > 0 {
+		return equal
+	}
+	return prefixed
+}
+
+// formatChoice returns the label displayed for given choice, including its description, if any
+func formatChoice(name, description string) string {
+	if description == "" {
+		return name
+	}
+	return fmt.Sprintf("%s %s", name, color.Ize(color.Gray, "- "+description))
+}
+
+// IsInteractive returns whether user can be prompted, that is when stdin is a terminal
+func IsInteractive() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
+// hasComboWithPrefix returns whether any of given combos starts with given names. To account for
+// multi-select variations, of which not all subsets are necessarily enumerated in combos, a name
+// also matches any combined name including it and a combined name matches any name.
+func hasComboWithPrefix(combos [][]string, names []string) bool {
+	for _, combo := range combos {
+		if len(combo) < len(names) {
+			continue
+		}
+		matches := true
+		for i, name := range names {
+			if !matchesComboName(combo[i], name) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesComboName(comboName, name string) bool {
+	return comboName == name ||
+		strings.Contains(name, yey.MultiSeparator) ||
+		stringIsInStrings(name, strings.Split(comboName, yey.MultiSeparator))
+}
+
+func areStringsInStrings(candidates []string, values []string) bool {
+	for _, candidate := range candidates {
+		if !stringIsInStrings(candidate, values) {
+			return false
+		}
+	}
+	return true
+}
+
+func stringIsInStrings(candidate string, values []string) bool {
+	for _, value := range values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
 // PromptImagesAndPlatforms prompts user to multi-select among given images
 func PromptImagesAndPlatforms(allImages []yey.ImageAndPlatform) ([]yey.ImageAndPlatform, error) {
 	// Format list of options
@@ -109,12 +323,19 @@ func PromptImagesAndPlatforms(allImages []yey.ImageAndPlatform) ([]yey.ImageAndP
 }
 
 // PromptContainers prompts user to multi-select among given containers and optionally also
-// other containers (which are displayed in yellow)
-func PromptContainers(containers []string, otherContainers []string, message string) ([]string, error) {
+// other containers (which are displayed in yellow, along with their RC file path)
+func PromptContainers(containers []docker.Container, otherContainers []docker.Container, message string) ([]docker.Container, error) {
 	// Combine containers and otherContainers (in yellow)
-	options := containers
+	var options []string
+	for _, container := range containers {
+		options = append(options, formatContainer(container))
+	}
 	for _, container := range otherContainers {
-		options = append(options, color.Ize(color.Yellow, container))
+		option := formatContainer(container)
+		if container.Path != "" {
+			option = fmt.Sprintf("%s (%s)", option, container.Path)
+		}
+		options = append(options, color.Ize(color.Yellow, option))
 	}
 
 	prompt := &survey.MultiSelect{
@@ -126,11 +347,20 @@ func PromptContainers(containers []string, otherContainers []string, message str
 		return nil, err
 	}
 
-	// Lookup container names based on indices
-	allContainers := append(containers, otherContainers...)
-	var selectedContainers []string
+	// Lookup containers based on indices
+	allContainers := append(append([]docker.Container(nil), containers...), otherContainers...)
+	var selectedContainers []docker.Container
 	for _, selectedIndex := range selectedIndices {
 		selectedContainers = append(selectedContainers, allContainers[selectedIndex])
 	}
 	return selectedContainers, nil
 }
+
+// formatContainer returns the context names of given container, followed by its name in gray, or
+// only its raw name for unlabeled containers created by older versions of yey
+func formatContainer(container docker.Container) string {
+	if container.Legacy {
+		return container.Name
+	}
+	return fmt.Sprintf("%s %s", container.ContextName(), color.Ize(color.Gray, container.Name))
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"testing"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestContexts() yey.Contexts {
+	return yey.Contexts{Context: yey.Context{
+		Variations: yey.Variations{
+			{
+				Name:    "env",
+				Default: "dev",
+				Contexts: map[string]yey.Context{
+					"dev":  {Name: "dev"},
+					"prod": {Name: "prod"},
+				},
+			},
+			{
+				Name: "lang",
+				Contexts: map[string]yey.Context{
+					"go":   {Name: "go"},
+					"node": {Name: "node"},
+				},
+			},
+		},
+	}}
+}
+
+func TestGetOrPromptContextsUsesDefaultsWhenNonInteractive(t *testing.T) {
+	names, err := GetOrPromptContexts(getTestContexts(), nil, nil)
+	assert.EqualError(t, err, "cannot prompt for lang in non-interactive mode and no default was specified")
+	assert.Nil(t, names)
+
+	ctx := getTestContexts()
+	ctx.Variations[1].Default = "go"
+	names, err = GetOrPromptContexts(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "go"}, names)
+}
+
+func TestGetOrPromptContextsWithArgs(t *testing.T) {
+	names, err := GetOrPromptContexts(getTestContexts(), []string{"prod", "node"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "node"}, names)
+}
+
+func TestHasComboWithPrefix(t *testing.T) {
+	combos := [][]string{{"dev", "go"}, {"prod", "node"}}
+	assert.True(t, hasComboWithPrefix(combos, []string{"dev"}))
+	assert.True(t, hasComboWithPrefix(combos, []string{"prod", "node"}))
+	assert.False(t, hasComboWithPrefix(combos, []string{"prod", "go"}))
+	assert.False(t, hasComboWithPrefix(combos, []string{"dev", "go", "extra"}))
+}
+
+func TestGetOrPromptContextsWithPreset(t *testing.T) {
+	contexts := getTestContexts()
+	contexts.Presets = map[string][]string{
+		"pn": {"prod", "node"},
+	}
+
+	names, err := GetOrPromptContexts(contexts, []string{"pn"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "node"}, names)
+}
+
+func TestGetOrPromptContextsNormalizesMultiSelection(t *testing.T) {
+	contexts := getTestContexts()
+	contexts.Variations = append(contexts.Variations, yey.Variation{
+		Name:  "tools",
+		Multi: true,
+		Names: []string{"aws", "gcp", "terraform"},
+		Contexts: map[string]yey.Context{
+			"aws":       {Name: "aws"},
+			"gcp":       {Name: "gcp"},
+			"terraform": {Name: "terraform"},
+		},
+	})
+
+	names, err := GetOrPromptContexts(contexts, []string{"dev", "go", "terraform+aws"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "go", "aws+terraform"}, names)
+
+	_, err = GetOrPromptContexts(contexts, []string{"dev", "go", "aws+azure"}, nil)
+	assert.EqualError(t, err, `context "azure" not found in variation "tools"`)
+}
+
+func TestHasComboWithPrefixMatchesMultiNames(t *testing.T) {
+	combos := [][]string{{"dev", "aws+gcp", "go"}}
+	assert.True(t, hasComboWithPrefix(combos, []string{"dev", "gcp"}))
+	assert.True(t, hasComboWithPrefix(combos, []string{"dev", "aws+gcp+terraform", "go"}))
+	assert.False(t, hasComboWithPrefix(combos, []string{"dev", "terraform"}))
+}
+
+func TestGetOrPromptContextsMatchesPrefixes(t *testing.T) {
+	names, err := GetOrPromptContexts(getTestContexts(), []string{"p", "G"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "go"}, names)
+
+	contexts := getTestContexts()
+	contexts.Variations[0].Contexts["preview"] = yey.Context{Name: "preview"}
+	_, err = GetOrPromptContexts(contexts, []string{"p", "go"}, nil)
+	assert.EqualError(t, err, `ambiguous env "p": could be any of preview, prod`)
+
+	names, err = GetOrPromptContexts(contexts, []string{"pro", "go"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "go"}, names)
+}
+
+func TestGetOrPromptContextsWithExactNames(t *testing.T) {
+	yey.IsExact = true
+	defer func() { yey.IsExact = false }()
+
+	_, err := GetOrPromptContexts(getTestContexts(), []string{"p", "go"}, nil)
+	assert.EqualError(t, err, `context "p" not found in variation "env"`)
+}
+
+func TestFindChoices(t *testing.T) {
+	names := []string{"Prod", "prod", "production", "dev"}
+	assert.Equal(t, []string{"prod"}, findChoices(names, "prod"))
+	assert.Equal(t, []string{"Prod", "prod"}, findChoices(names, "PROD"))
+	assert.Equal(t, []string{"production"}, findChoices(names, "produc"))
+	assert.Equal(t, []string{"dev"}, findChoices(names, "D"))
+	assert.Nil(t, findChoices(names, "stg"))
+	assert.Nil(t, findChoices(names, ""))
+}
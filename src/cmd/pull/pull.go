@@ -36,10 +36,13 @@ func New() *cobra.Command {
 }
 
 func run(ctx context.Context, options pullOptions) error {
-	contexts, err := yey.LoadContexts()
+	contexts, err := yey.LoadContextsWithChoices()
 	if err != nil {
 		return err
 	}
+	if err := docker.UseRuntime(contexts.Runtime); err != nil {
+		return err
+	}
 
 	// Determine which images to pull
 	imagesAndPlatforms := contexts.GetAllImagesAndPlatforms("")
@@ -43,48 +43,28 @@ func run(ctx context.Context, names []string, options RemoveOptions) error {
 	if err != nil {
 		return err
 	}
+	if err := docker.UseRuntime(contexts.Runtime); err != nil {
+		return err
+	}
 
-	containers, err := docker.ListContainers(ctx, true)
+	containers, err := docker.ListContainersWithLegacy(ctx, true, contexts.Path)
 	if err != nil {
 		return fmt.Errorf("failed to list containers to prompt for removal: %w", err)
 	}
 	totalCount := len(containers)
 
-	// Compute all valid contexts
-	combos := contexts.GetCombos()
-	var validContexts []yey.Context
-	for _, combo := range combos {
-		context, err := contexts.GetContext(combo)
-		if err != nil {
-			return fmt.Errorf("failed to get context to prompt for removal: %w", err)
-		}
-		validContexts = append(validContexts, context)
-	}
-
-	// Compute all valid containers
-	var validContainers []string
-	for _, validContext := range validContexts {
-		container := yey.ContainerName(contexts.Path, validContext)
-
-		// Found in list of containers?
-		for i := range containers {
-			if containers[i] == container {
-				validContainers = append(validContainers, container)
-				// Remove from list of containers
-				containers = append(containers[:i], containers[i+1:]...)
-				break
-			}
+	// Split project containers from other containers
+	var projectContainers, otherContainers []docker.Container
+	for _, container := range containers {
+		if container.Path == contexts.Path {
+			projectContainers = append(projectContainers, container)
+		} else if options.All {
+			otherContainers = append(otherContainers, container)
 		}
 	}
 
-	// Include all containers?
-	var otherContainers []string
-	if options.All {
-		otherContainers = containers
-	}
-
 	// Abort if no containers to remove
-	if len(validContainers) == 0 && len(otherContainers) == 0 {
+	if len(projectContainers) == 0 && len(otherContainers) == 0 {
 		if totalCount > 0 {
 			fmt.Fprintln(os.Stderr, color.Ize(color.Green, fmt.Sprintf("no project-specific yey containers found, but %d other(s) were found that you could include with --all flag", totalCount)))
 			return nil
@@ -94,14 +74,14 @@ func run(ctx context.Context, names []string, options RemoveOptions) error {
 	}
 
 	// Prompt
-	selectedContainers, err := cmd.PromptContainers(validContainers, otherContainers, "Select containers to remove")
+	selectedContainers, err := cmd.PromptContainers(projectContainers, otherContainers, "Select containers to remove")
 	if err != nil {
 		return fmt.Errorf("failed to prompt for containers: %w", err)
 	}
 
 	// Prompt user to confirm force removing currently running containers
 	if !options.Force {
-		runningContainers, err := getRunningContainers(ctx, selectedContainers)
+		runningContainers, err := getRunningContainers(ctx, selectedContainers, contexts.Path)
 		if err != nil {
 			return err
 		}
@@ -111,12 +91,12 @@ func run(ctx context.Context, names []string, options RemoveOptions) error {
 			if err != nil {
 				return err
 			}
-			selectedContainers = subtractStrings(selectedContainers, runningContainers)
+			selectedContainers = subtractContainers(selectedContainers, runningContainers)
 
 			// Force remove user-confirmed running containers
 			for _, container := range forceRemoveContainers {
 				opt := docker.RemoveOptions{Force: true}
-				if err := remove(ctx, container, opt); err != nil {
+				if err := remove(ctx, container.Name, opt); err != nil {
 					return err
 				}
 			}
@@ -126,46 +106,41 @@ func run(ctx context.Context, names []string, options RemoveOptions) error {
 	// Remove selected containers
 	for _, container := range selectedContainers {
 		opt := docker.RemoveOptions{Force: options.Force}
-		if err := remove(ctx, container, opt); err != nil {
+		if err := remove(ctx, container.Name, opt); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func getRunningContainers(ctx context.Context, containers []string) ([]string, error) {
-	runningContainers, err := docker.ListContainers(ctx, true)
+func getRunningContainers(ctx context.Context, containers []docker.Container, path string) ([]docker.Container, error) {
+	runningContainers, err := docker.ListContainersWithLegacy(ctx, false, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list running containers: %w", err)
 	}
 
-	intersection := intersectStrings(containers, runningContainers)
-	return intersection, nil
-}
-
-func subtractStrings(superset []string, subset []string) []string {
-	var results []string
-	for _, value := range superset {
-		if !stringIsInStrings(value, subset) {
-			results = append(results, value)
+	var results []docker.Container
+	for _, container := range containers {
+		if containerIsInContainers(container, runningContainers) {
+			results = append(results, container)
 		}
 	}
-	return results
+	return results, nil
 }
 
-func intersectStrings(set1 []string, set2 []string) []string {
-	var results []string
-	for _, value1 := range set1 {
-		if stringIsInStrings(value1, set2) {
-			results = append(results, value1)
+func subtractContainers(superset []docker.Container, subset []docker.Container) []docker.Container {
+	var results []docker.Container
+	for _, container := range superset {
+		if !containerIsInContainers(container, subset) {
+			results = append(results, container)
 		}
 	}
 	return results
 }
 
-func stringIsInStrings(candidate string, values []string) bool {
-	for _, value := range values {
-		if value == candidate {
+func containerIsInContainers(candidate docker.Container, containers []docker.Container) bool {
+	for _, container := range containers {
+		if container.Name == candidate.Name {
 			return true
 		}
 	}
@@ -12,10 +12,19 @@ func NewRoot() *cobra.Command {
 		Short:        "An interactive, human-friendly docker launcher for dev and devops",
 		Long:         "An interactive, human-friendly docker launcher for dev and devops",
 		SilenceUsage: true,
+
+		// Errors are reported by main, which propagates exit code of containers silently
+		SilenceErrors: true,
 	}
 
 	c.PersistentFlags().BoolVarP(&yey.IsVerbose, "verbose", "v", false, "output verbose messages to stderr")
 	c.PersistentFlags().BoolVar(&yey.IsDryRun, "dry-run", false, "output docker command to stdout instead of executing it")
+	c.PersistentFlags().BoolVarP(&yey.IsAssumeYes, "yes", "y", false, "use default choices without prompting")
+	c.PersistentFlags().BoolVar(&yey.IsExact, "exact", false, "require context names passed as arguments to match exactly, without prefix or case-insensitive matching")
+	c.PersistentFlags().BoolVar(&yey.IsStrict, "strict", false, "fail on problems found in context files, rather than reporting them as warnings")
+	c.PersistentFlags().BoolVar(&yey.IsOffline, "offline", false, "only use cached copies of remote context files")
+	c.PersistentFlags().BoolVar(&yey.IsRefresh, "refresh", false, "force fetching remote context files again, ignoring cache")
+	c.PersistentFlags().DurationVar(&yey.CacheTTL, "cache-ttl", yey.CacheTTL, "duration during which cached remote context files are considered fresh")
 
 	return c
 }
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/silphid/yey/src/cmd"
 	yey "github.com/silphid/yey/src/internal"
@@ -20,47 +21,71 @@ func New() *cobra.Command {
 	options := Options{Remove: new(bool)}
 
 	cmd := &cobra.Command{
-		Use:   "run",
-		Short: "Runs container using given context",
+		Use:   "run [names...] [-- command...]",
+		Short: "Runs container using given context, optionally running given one-off command in it",
 		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if !cmd.Flag("rm").Changed {
 				options.Remove = nil
 			}
-			return run(cmd.Context(), args, options)
+			var names []string
+			names, options.Command = splitArgs(args, cmd.ArgsLenAtDash())
+			return Run(cmd.Context(), names, options)
 		},
 	}
 
 	cmd.Flags().BoolVar(options.Remove, "rm", false, "remove container upon exit")
 	cmd.Flags().BoolVar(&options.Reset, "reset", false, "remove previous container before starting a fresh one")
+	cmd.Flags().BoolVar(&options.Recreate, "recreate", false, "replace containers made stale by configuration changes without prompting")
 	cmd.Flags().BoolVar(&options.Pull, "pull", false, "force pulling image from registry before running")
+	cmd.Flags().StringArrayVar(&options.Inputs, "input", nil, "value of input, as key=value (can be repeated)")
 
 	return cmd
 }
 
 type Options struct {
-	Remove *bool
-	Reset  bool
-	Pull   bool
+	Remove   *bool
+	Reset    bool
+	Recreate bool
+	Pull     bool
+	Inputs   []string
+
+	// Command is a one-off command replacing context's Cmd, as passed after `--`
+	Command []string
 }
 
-func run(ctx context.Context, names []string, options Options) error {
-	contexts, err := yey.LoadContexts()
+// splitArgs splits given args into context names and command, based on the index of `--` separator
+// (or -1 when there is none)
+func splitArgs(args []string, dash int) ([]string, []string) {
+	if dash < 0 {
+		return args, nil
+	}
+	return args[:dash], args[dash:]
+}
+
+// Run runs container using context with given names, prompting for missing ones
+func Run(ctx context.Context, names []string, options Options) error {
+	inputValues, err := cmd.ParseInputArgs(options.Inputs)
+	if err != nil {
+		return err
+	}
+
+	contexts, err := yey.LoadContextsWithChoices()
 	if err != nil {
 		return err
 	}
 
-	lastNames, err := cmd.LoadLastNames()
+	lastNames, err := cmd.LoadLastNames(contexts.Path)
 	if err != nil {
 		return err
 	}
 
-	names, err = cmd.GetOrPromptContexts(contexts.Context, names, lastNames)
+	names, err = cmd.GetOrPromptContexts(contexts, names, lastNames)
 	if err != nil {
 		return err
 	}
 
-	err = cmd.SaveLastNames(names)
+	err = cmd.SaveLastNames(contexts.Path, names)
 	if err != nil {
 		return err
 	}
@@ -72,6 +97,19 @@ func run(ctx context.Context, names []string, options Options) error {
 	if options.Remove != nil {
 		yeyContext.Remove = options.Remove
 	}
+	if err := docker.UseRuntime(yeyContext.Runtime); err != nil {
+		return err
+	}
+
+	// Inputs
+	inputValues, err = cmd.GetOrPromptInputs(yeyContext.Inputs, inputValues)
+	if err != nil {
+		return err
+	}
+	yeyContext, err = yeyContext.ResolveInputs(inputValues)
+	if err != nil {
+		return err
+	}
 
 	if yeyContext.Image == "" {
 		var err error
@@ -84,9 +122,26 @@ func run(ctx context.Context, names []string, options Options) error {
 
 	yey.Log("context:\n--\n%v--", yeyContext)
 
-	// Container name
+	// Container name and labels
 	containerName := yey.ContainerName(contexts.Path, yeyContext)
+	containerName, err = handleStaleContainers(ctx, contexts.Path, names, yeyContext, containerName, options)
+	if err != nil {
+		return err
+	}
 	yey.Log("container: %s", containerName)
+	runOptions := docker.RunOptions{
+		Labels: yey.ContainerLabels(contexts.Path, names, yeyContext, time.Now()),
+	}
+
+	// Input values are only expanded after logging context and computing container name,
+	// so that secrets are not logged and only identity inputs affect container name
+	yeyContext = yeyContext.ExpandInputs()
+
+	// Command is only applied once container name is determined, so that it does not affect it
+	if len(options.Command) > 0 {
+		yeyContext.Cmd = options.Command
+		runOptions.OneOff = true
+	}
 
 	// Reset
 	if options.Reset {
@@ -97,7 +152,6 @@ func run(ctx context.Context, names []string, options Options) error {
 	}
 
 	// Working directory
-	var runOptions docker.RunOptions
 	workDir, err := getContainerWorkDir(yeyContext)
 	if err != nil {
 		return err
@@ -116,7 +170,7 @@ func run(ctx context.Context, names []string, options Options) error {
 	}
 
 	// Banner
-	if !yey.IsDryRun {
+	if !yey.IsDryRun && !runOptions.OneOff {
 		if err := ShowBanner(yeyContext.Name); err != nil {
 			return err
 		}
@@ -1,8 +1,12 @@
 package run
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/silphid/yey/src/internal/docker"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,3 +23,62 @@ func TestShouldPull(t *testing.T) {
 	assert.Equal(t, true, shouldPull("gcr.io/project-1a2b3c4d5e/abcdef/image:latest"))
 	assert.Equal(t, false, shouldPull("gcr.io/project-1a2b3c4d5e/abcdef/image:0.123.4"))
 }
+
+func TestFindStaleContainers(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	path := "/project/.yeyrc.yaml"
+	names := []string{"prod", "go"}
+	yeyContext := yey.Context{Name: "prod go", Image: "alpine"}
+	newContainer := func(names []string, hash string, created time.Time) docker.Container {
+		name := yey.ContainerNameWithHash(path, yey.Context{Name: strings.Join(names, " ")}, hash)
+		return docker.Container{Name: name, Path: path, Names: names, Hash: hash, Created: created}
+	}
+	older := newContainer(names, "111", now)
+	newer := newContainer(names, "222", now.Add(time.Hour))
+	current := newContainer(names, yey.ConfigHash(yeyContext), now)
+	otherNames := newContainer([]string{"dev", "go"}, "444", now)
+	otherPath := docker.Container{Name: "otherPath", Path: "/other/.yeyrc.yaml", Names: names, Hash: "555", Created: now}
+	containers := []docker.Container{older, current, otherNames, newer, otherPath}
+
+	stale := findStaleContainers(containers, path, names, yeyContext)
+
+	assert.Equal(t, []docker.Container{newer, older}, stale)
+}
+
+func TestFindStaleContainersWithIdentityInputs(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	path := "/project/.yeyrc.yaml"
+	names := []string{"prod"}
+	withTicket := func(context yey.Context, ticket string) yey.Context {
+		context.Inputs = []yey.Input{{Name: "ticket", Identity: true, Value: ticket}}
+		return context
+	}
+	oldContext := yey.Context{Name: "prod", Image: "alpine:1"}
+	newContext := yey.Context{Name: "prod", Image: "alpine:2"}
+	newContainer := func(context yey.Context) docker.Container {
+		return docker.Container{Name: yey.ContainerName(path, context), Path: path, Names: names, Hash: yey.ConfigHash(context), Created: now}
+	}
+	ticket1 := newContainer(withTicket(oldContext, "INC-1"))
+	ticket2 := newContainer(withTicket(oldContext, "INC-2"))
+	noTicket := newContainer(oldContext)
+	containers := []docker.Container{ticket1, ticket2, noTicket}
+
+	assert.Equal(t, []docker.Container{ticket1}, findStaleContainers(containers, path, names, withTicket(newContext, "INC-1")))
+	assert.Equal(t, []docker.Container{ticket2}, findStaleContainers(containers, path, names, withTicket(newContext, "INC-2")))
+	assert.Equal(t, []docker.Container{noTicket}, findStaleContainers(containers, path, names, newContext))
+	assert.Empty(t, findStaleContainers(containers, path, names, withTicket(newContext, "INC-3")))
+}
+
+func TestSplitArgs(t *testing.T) {
+	names, command := splitArgs([]string{"prod", "go"}, -1)
+	assert.Equal(t, []string{"prod", "go"}, names)
+	assert.Nil(t, command)
+
+	names, command = splitArgs([]string{"prod", "go", "make", "test"}, 2)
+	assert.Equal(t, []string{"prod", "go"}, names)
+	assert.Equal(t, []string{"make", "test"}, command)
+
+	names, command = splitArgs([]string{"make", "test"}, 0)
+	assert.Empty(t, names)
+	assert.Equal(t, []string{"make", "test"}, command)
+}
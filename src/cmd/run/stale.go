@@ -0,0 +1,131 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/TwinProduction/go-color"
+	"github.com/silphid/yey/src/cmd"
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/silphid/yey/src/internal/docker"
+)
+
+// staleAction is what to do when stale containers are found for current context
+type staleAction int
+
+const (
+	// staleRecreate removes stale containers and runs a fresh one with current configuration
+	staleRecreate staleAction = iota
+
+	// staleKeep keeps using the most recent stale container, ignoring configuration changes
+	staleKeep
+
+	// staleBoth runs a fresh container with current configuration, leaving stale ones untouched
+	staleBoth
+)
+
+var staleActionLabels = []string{
+	"Recreate container with new configuration",
+	"Keep using previous container",
+	"Run both containers",
+}
+
+// findStaleContainers returns the containers created for same RC file, context names and identity
+// input values, but with a different configuration hash, from most to least recent
+func findStaleContainers(containers []docker.Container, path string, names []string, yeyContext yey.Context) []docker.Container {
+	configHash := yey.ConfigHash(yeyContext)
+	var stale []docker.Container
+	for _, container := range containers {
+		if container.Path != path || container.ContextName() != strings.Join(names, " ") || container.Hash == configHash {
+			continue
+		}
+
+		// Containers created for other identity input values are distinct rather than stale
+		if container.Name != yey.ContainerNameWithHash(path, yeyContext, container.Hash) {
+			continue
+		}
+		stale = append(stale, container)
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].Created.After(stale[j].Created)
+	})
+	return stale
+}
+
+// handleStaleContainers detects containers made stale by changes to configuration of given context,
+// shows what changed and lets user decide what to do about them. It returns the name of container
+// to run, which is either given container name or that of the previous container user chose to keep.
+func handleStaleContainers(ctx context.Context, path string, names []string, yeyContext yey.Context, containerName string, options Options) (string, error) {
+	containers, err := docker.ListContainers(ctx, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %w", err)
+	}
+	stale := findStaleContainers(containers, path, names, yeyContext)
+	if len(stale) == 0 {
+		return containerName, nil
+	}
+	previous := stale[0]
+
+	// Show diff
+	previousContext, err := docker.GetContainerLabel(ctx, previous.Name, yey.LabelContext)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr, color.Ize(color.Yellow, fmt.Sprintf("configuration has changed since container %q was created:", previous.Name)))
+	if previousContext == "" {
+		fmt.Fprintln(os.Stderr, color.Ize(color.Gray, "(previous configuration unavailable)"))
+	} else {
+		printDiff(yey.DiffLines(previousContext, yeyContext.String()))
+	}
+
+	// Determine action
+	action := staleRecreate
+	if !options.Recreate {
+		if yey.IsAssumeYes || !cmd.IsInteractive() {
+			yey.Warn("running new container alongside previous one (use --recreate flag to replace it)")
+			action = staleBoth
+		} else {
+			prompt := &survey.Select{
+				Message: "What do you want to do?",
+				Options: staleActionLabels,
+			}
+			var selectedIndex int
+			if err := survey.AskOne(prompt, &selectedIndex); err != nil {
+				return "", err
+			}
+			action = staleAction(selectedIndex)
+		}
+	}
+
+	switch action {
+	case staleRecreate:
+		for _, container := range stale {
+			yey.Log("removing previous container %q", container.Name)
+			if err := docker.Remove(ctx, container.Name, docker.RemoveOptions{Force: true}); err != nil {
+				return "", fmt.Errorf("failed to remove container %q: %w", container.Name, err)
+			}
+		}
+		return containerName, nil
+	case staleKeep:
+		return previous.Name, nil
+	default:
+		return containerName, nil
+	}
+}
+
+// printDiff prints given diff lines to stderr, with removed lines in red and added ones in green
+func printDiff(lines []string) {
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, yey.DiffRemoved):
+			line = color.Ize(color.Red, line)
+		case strings.HasPrefix(line, yey.DiffAdded):
+			line = color.Ize(color.Green, line)
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
@@ -2,7 +2,6 @@ package tidy
 
 import (
 	"context"
-	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -29,38 +28,53 @@ func New() *cobra.Command {
 }
 
 func run(ctx context.Context, options docker.RemoveOptions) error {
-	contexts, err := yey.LoadContexts()
+	// Dynamic choices are generated as for run command, so that containers are matched against the
+	// same contexts they were created for
+	contexts, err := yey.LoadContextsWithChoices()
 	if err != nil {
 		return err
 	}
-
-	validNames := make(map[string]struct{})
-	combos := contexts.GetCombos()
-	for _, combo := range combos {
-		ctx, err := contexts.GetContext(combo)
-		if err != nil {
-			return err
-		}
-		validNames[yey.ContainerName(contexts.Path, ctx)] = struct{}{}
+	if err := docker.UseRuntime(contexts.Runtime); err != nil {
+		return err
 	}
 
-	prefix := yey.ContainerPathPrefix(contexts.Path)
-
-	names, err := docker.ListContainers(ctx, true)
+	containers, err := docker.ListContainersWithLegacy(ctx, true, contexts.Path)
 	if err != nil {
 		return err
 	}
 
 	var unreferencedContainers []string
-	for _, container := range names {
-		if !strings.HasPrefix(container, prefix) {
+	for _, container := range findUnreferencedContainers(contexts, containers) {
+		yey.Log("unreferenced container: %s (%s)", container.Name, container.ContextName())
+		unreferencedContainers = append(unreferencedContainers, container.Name)
+	}
+
+	return docker.RemoveMany(ctx, unreferencedContainers, options)
+}
+
+// findUnreferencedContainers returns the containers of given contexts' RC file whose configuration
+// no longer matches that of the context they were created for, or whose context no longer exists,
+// along with those created by older versions of yey (which cannot be matched to any configuration)
+func findUnreferencedContainers(contexts yey.Contexts, containers []docker.Container) []docker.Container {
+	var unreferenced []docker.Container
+	for _, container := range containers {
+		if container.Path != contexts.Path {
 			continue
 		}
-		if _, ok := validNames[container]; ok {
+		if container.Legacy {
+			unreferenced = append(unreferenced, container)
 			continue
 		}
-		unreferencedContainers = append(unreferencedContainers, container)
-	}
 
-	return docker.RemoveMany(ctx, unreferencedContainers, options)
+		// Rebuild context container was created for
+		context, err := contexts.GetContext(container.Names)
+		if err != nil {
+			unreferenced = append(unreferenced, container)
+			continue
+		}
+		if yey.ConfigHash(context) != container.Hash {
+			unreferenced = append(unreferenced, container)
+		}
+	}
+	return unreferenced
 }
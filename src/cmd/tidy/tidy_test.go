@@ -0,0 +1,42 @@
+package tidy
+
+import (
+	"testing"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/silphid/yey/src/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindUnreferencedContainers(t *testing.T) {
+	// Seven choices make 127 subsets, more than are listed as combos
+	choices := make(map[string]yey.Context)
+	for _, name := range []string{"aws", "gcp", "azure", "terraform", "kubectl", "helm", "vault"} {
+		choices[name] = yey.Context{Name: name, Env: map[string]string{name: "true"}}
+	}
+	contexts := yey.Contexts{
+		Path: "/project/.yeyrc.yaml",
+		Context: yey.Context{
+			Image: "alpine",
+			Variations: yey.Variations{
+				{Name: "tools", Multi: true, Contexts: choices},
+			},
+		},
+	}
+	names := []string{"aws+gcp+azure+terraform+kubectl+helm+vault"}
+	require.NotContains(t, contexts.GetCombos(), names)
+	context, err := contexts.GetContext(names)
+	require.NoError(t, err)
+
+	current := docker.Container{Name: "current", Path: contexts.Path, Names: names, Hash: yey.ConfigHash(context)}
+	stale := docker.Container{Name: "stale", Path: contexts.Path, Names: names, Hash: "123"}
+	removed := docker.Container{Name: "removed", Path: contexts.Path, Names: []string{"openstack"}, Hash: "456"}
+	otherPath := docker.Container{Name: "otherPath", Path: "/other/.yeyrc.yaml", Names: []string{"openstack"}, Hash: "789"}
+	legacy := docker.Container{Name: "legacy", Path: contexts.Path, Legacy: true}
+	otherLegacy := docker.Container{Name: "otherLegacy", Legacy: true}
+
+	unreferenced := findUnreferencedContainers(contexts, []docker.Container{current, stale, removed, otherPath, legacy, otherLegacy})
+
+	assert.Equal(t, []docker.Container{stale, removed, legacy}, unreferenced)
+}
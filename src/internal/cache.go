@@ -0,0 +1,97 @@
+package yey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const cacheDirEnvVar = "YEY_CACHE_DIR"
+
+var (
+	// IsOffline indicates that remote files should only be read from local cache
+	IsOffline bool
+
+	// IsRefresh indicates that remote files should be fetched again, regardless of cache TTL
+	IsRefresh bool
+
+	// CacheTTL is the duration during which cached remote files are considered fresh
+	CacheTTL = time.Hour
+)
+
+// GetCacheDir returns the directory where yey caches files, which defaults to the user's
+// cache dir and can be overridden via the YEY_CACHE_DIR env var
+func GetCacheDir() (string, error) {
+	if dir := os.Getenv(cacheDirEnvVar); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine cache dir: %w", err)
+	}
+	return filepath.Join(dir, "yey"), nil
+}
+
+// writeFileAtomically writes data to a temp file in same directory and then renames it to
+// given path, so that concurrent readers never see a partially written file
+func writeFileAtomically(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+const (
+	// lockRetryDelay is the delay between attempts to acquire a lock held by another process
+	lockRetryDelay = 20 * time.Millisecond
+
+	// lockTimeout is the maximum duration to wait for a lock held by another process
+	lockTimeout = 5 * time.Second
+
+	// staleLockAge is the age beyond which a lock is considered abandoned by a crashed process
+	staleLockAge = 30 * time.Second
+)
+
+// lockFile acquires an exclusive lock on given file, by creating a companion `.lock` file, waiting
+// for other processes to release it, and returns a function releasing the lock
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %q: %w", path, err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			Warn("removing stale lock %q", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %q to be released", lockPath)
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
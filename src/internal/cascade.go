@@ -0,0 +1,87 @@
+package yey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+)
+
+// isCascading returns whether given context file data opts into cascade mode
+func isCascading(data []byte) bool {
+	var file struct {
+		Cascade bool
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return false
+	}
+	return file.Cascade
+}
+
+// getCascadingContextFilePaths returns the paths of all context files found from root directory
+// down to given directory, preceded by the one in home directory, if any, in merge order
+func getCascadingContextFilePaths(dir string) ([]string, error) {
+	var paths []string
+	for {
+		candidate := filepath.Join(dir, yeyRCFileName)
+		_, err := os.Stat(candidate)
+		if err == nil {
+			paths = append([]string{candidate}, paths...)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read context file: %w", err)
+		}
+		if dir == "/" || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	// Prepend home context file, unless already part of hierarchy
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine home dir: %w", err)
+	}
+	homePath := filepath.Join(home, yeyRCFileName)
+	if _, err := os.Stat(homePath); err == nil && !stringIsInStrings(homePath, paths) {
+		paths = append([]string{homePath}, paths...)
+	}
+
+	return paths, nil
+}
+
+// loadCascadingContexts reads, parses and merges all given context files in order
+func loadCascadingContexts(paths []string) (Contexts, error) {
+	Log("cascading context files:\n  %s", strings.Join(paths, "\n  "))
+
+	var merged Contexts
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Contexts{}, fmt.Errorf("failed to read context file: %w", err)
+		}
+		Log("merging context file: %s", path)
+		contexts, err := parseContextFile(path, data, nil, true)
+		if err != nil {
+			return Contexts{}, fmt.Errorf("failed to parse context file %q: %w", path, err)
+		}
+		if i == 0 {
+			merged = contexts
+		} else {
+			merged = merged.Merge(contexts)
+		}
+	}
+	return merged, nil
+}
+
+func stringIsInStrings(candidate string, values []string) bool {
+	for _, value := range values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
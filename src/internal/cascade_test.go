@@ -0,0 +1,31 @@
+package yey
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCascadingContextFiles(t *testing.T) {
+	home := t.TempDir()
+	withHomeDir(t, home)
+	root := t.TempDir()
+
+	homeFile := writeFile(t, home, yeyRCFileName, "env:\n  TZ: home\n  LEVEL: home\n")
+	projectFile := writeFile(t, root, "projects/"+yeyRCFileName, "image: project_image\nenv:\n  LEVEL: project\n")
+	subFile := writeFile(t, root, "projects/sub/"+yeyRCFileName, "cascade: true\nenv:\n  LEVEL: sub\n")
+
+	assert.True(t, isCascading([]byte("cascade: true")))
+	assert.False(t, isCascading([]byte("image: alpine")))
+
+	paths, err := getCascadingContextFilePaths(filepath.Dir(subFile))
+	require.NoError(t, err)
+	assert.Equal(t, []string{homeFile, projectFile, subFile}, paths)
+
+	contexts, err := loadCascadingContexts(paths)
+	require.NoError(t, err)
+	assert.Equal(t, "project_image", contexts.Image)
+	assert.Equal(t, map[string]string{"TZ": "home", "LEVEL": "sub"}, contexts.Env)
+}
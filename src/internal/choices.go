@@ -0,0 +1,163 @@
+package yey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// choicesFromProperty is the reserved variation key for generating choices dynamically
+	choicesFromProperty = "choicesFrom"
+
+	// valuePlaceholder is replaced in template by each value output by command
+	valuePlaceholder = "{{value}}"
+)
+
+// ChoicesFrom describes how to generate a variation's choices dynamically, from the output of a
+// command run on host
+type ChoicesFrom struct {
+	// Command is the shell command outputting one choice name per line
+	Command string
+
+	// Template is the overrides of each generated choice, where `{{value}}` gets replaced with
+	// choice name
+	Template Context `yaml:",omitempty"`
+
+	// Cache is the duration during which command output is reused (not cached by default)
+	Cache time.Duration `yaml:",omitempty"`
+}
+
+// Clone returns a deep-copy of this definition
+func (c *ChoicesFrom) Clone() *ChoicesFrom {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.Template = c.Template.Clone()
+	return &clone
+}
+
+// generate returns the contexts generated from command output, along with their names in output order
+func (c ChoicesFrom) generate() (map[string]Context, []string, error) {
+	values, err := c.getValues()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contexts := make(map[string]Context, len(values))
+	var names []string
+	for _, value := range values {
+		if _, ok := contexts[value]; ok {
+			continue
+		}
+		context, err := mapContext(c.Template, "", func(s string, _ string) (string, error) {
+			return strings.ReplaceAll(s, valuePlaceholder, value), nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		context.Name = value
+		contexts[value] = context
+		names = append(names, value)
+	}
+	return contexts, names, nil
+}
+
+// getValues returns the non-empty lines output by command, possibly from cache
+func (c ChoicesFrom) getValues() ([]string, error) {
+	var cachePath string
+	if c.Cache > 0 {
+		dir, err := GetCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cachePath = filepath.Join(dir, "choices", hash(c.Command))
+		info, err := os.Stat(cachePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil && !IsRefresh && time.Since(info.ModTime()) < c.Cache {
+			Log("using cached choices of command: %s", c.Command)
+			data, err := os.ReadFile(cachePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read cached choices: %w", err)
+			}
+			return splitLines(data), nil
+		}
+	}
+
+	Log("running choices command: %s", c.Command)
+	var stderr bytes.Buffer
+	cmd := shellCommand(c.Command)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run choices command %q: %w: %s", c.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if cachePath != "" {
+		if err := writeFileAtomically(cachePath, output); err != nil {
+			return nil, fmt.Errorf("failed to cache choices: %w", err)
+		}
+	}
+	return splitLines(output), nil
+}
+
+// splitLines returns the trimmed non-empty lines of given data
+func splitLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// resolveChoices returns a copy of these variations where choices of variations defining `choicesFrom`
+// have been generated, recursively. Explicitly declared choices are merged on top of generated ones.
+func (l Variations) resolveChoices() (Variations, error) {
+	var resolved Variations
+	for _, variation := range l {
+		clone := variation.Clone()
+		if clone.ChoicesFrom != nil {
+			contexts, names, err := clone.ChoicesFrom.generate()
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate choices for variation %q: %w", variation.Name, err)
+			}
+			generated := Variation{Name: clone.Name, Contexts: contexts, Names: names}
+			clone.ChoicesFrom = nil
+			clone = generated.Merge(clone)
+		}
+		for name, context := range clone.Contexts {
+			var err error
+			if context.Variations, err = context.Variations.resolveChoices(); err != nil {
+				return nil, err
+			}
+			clone.Contexts[name] = context
+		}
+		resolved = append(resolved, clone)
+	}
+	return resolved, nil
+}
+
+// HasChoicesFrom returns whether any variation of given context, recursively, defines `choicesFrom`
+func (c Context) HasChoicesFrom() bool {
+	for _, variation := range c.Variations {
+		if variation.ChoicesFrom != nil {
+			return true
+		}
+		for _, child := range variation.Contexts {
+			if child.HasChoicesFrom() {
+				return true
+			}
+		}
+	}
+	return false
+}
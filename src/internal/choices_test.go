@@ -0,0 +1,133 @@
+package yey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveChoices(t *testing.T) {
+	contexts, err := parseContextFile("", []byte(`
+variations:
+  cluster:
+    choicesFrom:
+      command: printf 'staging\nprod\n\nstaging\n'
+      template:
+        description: Cluster {{value}}
+        env:
+          KUBE_CONTEXT: "{{value}}"
+    local:
+      env:
+        KUBE_CONTEXT: docker-desktop
+    prod:
+      env:
+        CONFIRM: "true"
+`), nil, true)
+	require.NoError(t, err)
+
+	contexts.Variations, err = contexts.Variations.resolveChoices()
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{{"staging"}, {"prod"}, {"local"}}, contexts.GetCombos())
+	assert.Equal(t, []string{"Cluster prod"}, contexts.GetDescriptions([]string{"prod"}))
+
+	ctx, err := contexts.GetContext([]string{"prod"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"KUBE_CONTEXT": "prod", "CONFIRM": "true"}, ctx.Env)
+
+	ctx, err = contexts.GetContext([]string{"local"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"KUBE_CONTEXT": "docker-desktop"}, ctx.Env)
+}
+
+func TestResolveChoicesFailsOnCommandError(t *testing.T) {
+	variations := Variations{
+		{Name: "cluster", ChoicesFrom: &ChoicesFrom{Command: "echo oops >&2; exit 1"}},
+	}
+
+	_, err := variations.resolveChoices()
+	assert.EqualError(t, err, `failed to generate choices for variation "cluster": failed to run choices command "echo oops >&2; exit 1": exit status 1: oops`)
+}
+
+func TestResolveChoicesUsesCache(t *testing.T) {
+	withCacheDir(t)
+	counter := filepath.Join(t.TempDir(), "counter")
+	variations := Variations{
+		{
+			Name: "workspace",
+			ChoicesFrom: &ChoicesFrom{
+				Command: "echo x >> " + counter + "; echo default",
+				Cache:   time.Minute,
+			},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		resolved, err := variations.resolveChoices()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"default"}, resolved[0].GetNames())
+	}
+	data, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\n", string(data))
+
+	IsRefresh = true
+	_, err = variations.resolveChoices()
+	require.NoError(t, err)
+	data, err = os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "x\nx\n", string(data))
+}
+
+func TestChoicesFromRequiresTrustedContextFile(t *testing.T) {
+	withCacheDir(t)
+	content := "variations:\n  cluster:\n    choicesFrom:\n      command: echo prod\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+	uri := server.URL + "/team.yaml"
+
+	_, err := parseContextFile("", []byte("parent: "+uri+"\n"), nil, true)
+	assert.EqualError(t, err, `failed to resolve parent context "`+uri+`": refusing to run choicesFrom commands of context file "`+uri+`": remote context files must be pinned with sha256 or signed`)
+
+	sum := sha256.Sum256([]byte(content))
+	contexts, err := parseContextFile("", []byte("parent:\n  uri: "+uri+"\n  sha256: "+hex.EncodeToString(sum[:])+"\n"), nil, true)
+	require.NoError(t, err)
+	contexts.Variations, err = contexts.Variations.resolveChoices()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"prod"}}, contexts.GetCombos())
+}
+
+func TestValidateChoicesFrom(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, yeyRCFileName, `
+variations:
+  cluster:
+    choicesFrom:
+      command: kubectl config get-contexts -o name
+      cache: forever
+      template:
+        imag: foo
+`)
+
+	diagnostics, err := ValidateContextFile(path)
+	require.NoError(t, err)
+
+	var actual []string
+	for _, diagnostic := range diagnostics {
+		actual = append(actual, diagnostic.String())
+	}
+	assert.Equal(t, []string{
+		path + `:6:14: expecting duration (ie: 30s, 5m, 1h) for "variations.cluster.choicesFrom.cache"`,
+		path + `:8:9: unknown field "variations.cluster.choicesFrom.template.imag" (did you mean "image"?)`,
+	}, actual)
+}
@@ -1,11 +1,23 @@
 package yey
 
 import (
-	"sort"
+	"strings"
 )
 
-// GetCombos returns the list of all possible context name combinations user can choose from
+// GetCombos returns the list of all possible context name combinations user can choose from,
+// excluding those forbidden by constraints
 func (c Context) GetCombos() [][]string {
+	combos := make([][]string, 0)
+	for _, combo := range c.getCombos() {
+		if c.CheckConstraints(combo) == nil {
+			combos = append(combos, combo)
+		}
+	}
+	return combos
+}
+
+// getCombos returns the list of all context name combinations, regardless of constraints
+func (c Context) getCombos() [][]string {
 	// Any child variations?
 	if len(c.Variations) > 0 {
 		variationCombos := c.Variations.getCombos()
@@ -44,7 +56,7 @@ func (variations Variations) getCombos() [][]string {
 			var newCombos [][]string
 			for _, combo := range combos {
 				for _, variationCombo := range variationCombos {
-					newCombos = append(newCombos, append(combo, variationCombo...))
+					newCombos = append(newCombos, append(append([]string(nil), combo...), variationCombo...))
 				}
 			}
 			combos = newCombos
@@ -54,18 +66,113 @@ func (variations Variations) getCombos() [][]string {
 }
 
 func (variation Variation) getCombos() [][]string {
-	// Extract variation's sorted context names
-	names := make([]string, 0)
-	for name := range variation.Contexts {
-		names = append(names, name)
+	if variation.Multi {
+		return variation.getMultiCombos()
 	}
-	sort.Strings(names)
 
-	// Recursively get all combos from child contexts
+	// Recursively get all combos from child contexts, in declaration order
 	var combos [][]string
-	for _, name := range names {
+	for _, name := range variation.GetNames() {
 		context := variation.Contexts[name]
-		combos = append(combos, context.GetCombos()...)
+		combos = append(combos, context.getCombos()...)
 	}
 	return combos
 }
+
+// maxMultiCombos is the maximum number of choice subsets enumerated for a multi-select variation,
+// beyond which larger subsets are omitted from combos
+const maxMultiCombos = 64
+
+// getMultiCombos returns the combos of multi-select variation, where each non-empty subset of
+// choices (from smallest to largest, up to maxMultiCombos subsets) is combined with the combos
+// of its choices' child variations
+func (variation Variation) getMultiCombos() [][]string {
+	var combos [][]string
+	for _, subset := range getSubsets(variation.GetNames(), maxMultiCombos) {
+		subsetCombos := [][]string{{strings.Join(subset, MultiSeparator)}}
+		for _, name := range subset {
+			context := variation.Contexts[name]
+			if len(context.Variations) == 0 {
+				continue
+			}
+			var newCombos [][]string
+			for _, combo := range subsetCombos {
+				for _, childCombo := range context.Variations.getCombos() {
+					newCombos = append(newCombos, append(append([]string(nil), combo...), childCombo...))
+				}
+			}
+			subsetCombos = newCombos
+		}
+		combos = append(combos, subsetCombos...)
+	}
+	return combos
+}
+
+// getSubsets returns up to max non-empty subsets of given names, by increasing size, preserving order
+func getSubsets(names []string, max int) [][]string {
+	var subsets [][]string
+	var collect func(start int, subset []string, size int)
+	collect = func(start int, subset []string, size int) {
+		if len(subsets) >= max {
+			return
+		}
+		if len(subset) == size {
+			subsets = append(subsets, append([]string(nil), subset...))
+			return
+		}
+		for i := start; i < len(names); i++ {
+			collect(i+1, append(subset, names[i]), size)
+		}
+	}
+	for size := 1; size <= len(names); size++ {
+		collect(0, nil, size)
+	}
+	return subsets
+}
+
+// walkSelection calls given function for each of given context names, along with the variation it
+// was selected from and the corresponding contexts (multiple ones for multi-select variations)
+func (c Context) walkSelection(names []string, fn func(variation Variation, name string, contexts []Context)) []string {
+	for _, variation := range c.Variations {
+		if len(names) == 0 {
+			break
+		}
+		name := names[0]
+		names = names[1:]
+		contexts, _ := variation.getSelectedContexts(name)
+		fn(variation, name, contexts)
+
+		// Recurse into selected contexts' child variations
+		for _, context := range contexts {
+			if len(context.Variations) > 0 {
+				names = context.walkSelection(names, fn)
+			}
+		}
+	}
+	return names
+}
+
+// GetDefaults returns, for each of given context names, whether it is the default choice of its variation
+func (c Context) GetDefaults(names []string) []bool {
+	var defaults []bool
+	c.walkSelection(names, func(variation Variation, name string, _ []Context) {
+		defaults = append(defaults, name == variation.Default)
+	})
+	return defaults
+}
+
+// GetDescriptions returns the description of each of given context names, where descriptions of
+// choices selected together in multi-select variations are joined with commas
+func (c Context) GetDescriptions(names []string) []string {
+	var descriptions []string
+	c.walkSelection(names, func(_ Variation, _ string, contexts []Context) {
+		var parts []string
+		for _, context := range contexts {
+			if context.Description != "" {
+				parts = append(parts, context.Description)
+			}
+		}
+		descriptions = append(descriptions, strings.Join(parts, ", "))
+	})
+	return descriptions
+}
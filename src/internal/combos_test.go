@@ -53,3 +53,113 @@ func TestGetCombos(t *testing.T) {
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestGetDefaults(t *testing.T) {
+	ctx := Context{
+		Variations: Variations{
+			Variation{
+				Name:    "variation1",
+				Default: "dev",
+				Contexts: map[string]Context{
+					"dev": {
+						Name: "dev",
+						Variations: Variations{
+							Variation{
+								Name:    "childVariation",
+								Default: "dev2",
+								Contexts: map[string]Context{
+									"dev1": {Name: "dev1"},
+									"dev2": {Name: "dev2"},
+								},
+							},
+						},
+					},
+					"prod": {Name: "prod"},
+				},
+			},
+			Variation{
+				Name: "variation2",
+				Contexts: map[string]Context{
+					"go": {Name: "go"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []bool{true, false, false}, ctx.GetDefaults([]string{"dev", "dev1", "go"}))
+	assert.Equal(t, []bool{true, true, false}, ctx.GetDefaults([]string{"dev", "dev2", "go"}))
+	assert.Equal(t, []bool{false, false}, ctx.GetDefaults([]string{"prod", "go"}))
+}
+
+func TestGetDescriptions(t *testing.T) {
+	ctx := Context{
+		Variations: Variations{
+			Variation{
+				Name: "variation1",
+				Contexts: map[string]Context{
+					"dev":  {Name: "dev", Description: "Development"},
+					"prod": {Name: "prod"},
+				},
+			},
+			Variation{
+				Name: "variation2",
+				Contexts: map[string]Context{
+					"go": {Name: "go", Description: "Go toolchain"},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"Development", "Go toolchain"}, ctx.GetDescriptions([]string{"dev", "go"}))
+	assert.Equal(t, []string{"", "Go toolchain"}, ctx.GetDescriptions([]string{"prod", "go"}))
+}
+
+func TestGetCombosWithMultiVariation(t *testing.T) {
+	ctx := Context{
+		Variations: Variations{
+			{
+				Name:  "tools",
+				Multi: true,
+				Names: []string{"aws", "gcp", "node"},
+				Contexts: map[string]Context{
+					"aws": {Name: "aws"},
+					"gcp": {Name: "gcp"},
+					"node": {
+						Name: "node",
+						Variations: Variations{
+							{
+								Name: "version",
+								Contexts: map[string]Context{
+									"16": {Name: "16"},
+									"18": {Name: "18"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, [][]string{
+		{"aws"},
+		{"gcp"},
+		{"node", "16"},
+		{"node", "18"},
+		{"aws+gcp"},
+		{"aws+node", "16"},
+		{"aws+node", "18"},
+		{"gcp+node", "16"},
+		{"gcp+node", "18"},
+		{"aws+gcp+node", "16"},
+		{"aws+gcp+node", "18"},
+	}, ctx.GetCombos())
+}
+
+func TestGetSubsetsIsCapped(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	subsets := getSubsets(names, maxMultiCombos)
+	assert.Len(t, subsets, maxMultiCombos)
+	assert.Equal(t, []string{"a"}, subsets[0])
+	assert.Equal(t, []string{"a", "b"}, subsets[len(names)])
+}
@@ -0,0 +1,76 @@
+package yey
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckConstraints returns an error if given combination of context names is forbidden by any
+// `exclude` rule or by the `requires`/`conflicts` constraints of the selected contexts
+func (c Context) CheckConstraints(names []string) error {
+	var rules [][]string
+	var selected []Context
+	c.collectConstraints(names, &rules, &selected)
+
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		for _, part := range strings.Split(name, MultiSeparator) {
+			set[part] = true
+		}
+	}
+
+	for _, rule := range rules {
+		if len(rule) > 0 && containsAll(set, rule) {
+			return fmt.Errorf("combination %q is not allowed: excluded by rule %q", strings.Join(names, " "), strings.Join(rule, " "))
+		}
+	}
+
+	for _, ctx := range selected {
+		for _, required := range stringsWithoutMarkers(ctx.Requires) {
+			if !set[required] {
+				return fmt.Errorf("combination %q is not allowed: %q requires %q", strings.Join(names, " "), ctx.Name, required)
+			}
+		}
+		for _, conflicting := range stringsWithoutMarkers(ctx.Conflicts) {
+			if set[conflicting] {
+				return fmt.Errorf("combination %q is not allowed: %q conflicts with %q", strings.Join(names, " "), ctx.Name, conflicting)
+			}
+		}
+	}
+
+	return nil
+}
+
+// collectConstraints walks variations along given context names and collects all exclude rules
+// found, as well as selected contexts, and returns remaining names (used for recursivity)
+func (c Context) collectConstraints(names []string, rules *[][]string, selected *[]Context) []string {
+	*rules = append(*rules, rulesWithoutMarkers(c.Exclude)...)
+	for _, variation := range c.Variations {
+		if len(names) == 0 {
+			break
+		}
+		name := names[0]
+		names = names[1:]
+
+		selection, err := variation.ParseSelection(name)
+		if err != nil {
+			continue
+		}
+		for _, choice := range selection {
+			ctx := variation.Contexts[choice]
+			ctx.Name = choice
+			*selected = append(*selected, ctx)
+			names = ctx.collectConstraints(names, rules, selected)
+		}
+	}
+	return names
+}
+
+func containsAll(set map[string]bool, values []string) bool {
+	for _, value := range values {
+		if !set[value] {
+			return false
+		}
+	}
+	return true
+}
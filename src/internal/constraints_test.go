@@ -0,0 +1,55 @@
+package yey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraints(t *testing.T) {
+	contexts, err := parseContextFile("", []byte(`
+exclude:
+  - [prod, local-db]
+variations:
+  env:
+    dev: {}
+    prod: {}
+  db:
+    local-db: {}
+    cloud-db:
+      conflicts: [dev]
+  lang:
+    go: {}
+    node:
+      requires: [dev]
+`), nil, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"dev", "local-db", "go"},
+		{"dev", "local-db", "node"},
+		{"prod", "cloud-db", "go"},
+	}, contexts.GetCombos())
+
+	cases := []struct {
+		names []string
+		error string
+	}{
+		{names: []string{"dev", "local-db", "node"}},
+		{names: []string{"prod", "local-db", "go"}, error: `combination "prod local-db go" is not allowed: excluded by rule "prod local-db"`},
+		{names: []string{"dev", "cloud-db", "go"}, error: `combination "dev cloud-db go" is not allowed: "cloud-db" conflicts with "dev"`},
+		{names: []string{"prod", "cloud-db", "node"}, error: `combination "prod cloud-db node" is not allowed: "node" requires "dev"`},
+	}
+	for _, c := range cases {
+		ctx, err := contexts.GetContext(c.names)
+		if c.error != "" {
+			assert.EqualError(t, err, c.error)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Nil(t, ctx.Exclude)
+		assert.Nil(t, ctx.Requires)
+		assert.Nil(t, ctx.Conflicts)
+	}
+}
@@ -16,18 +16,32 @@ type DockerBuild struct {
 
 // Context represents execution configuration for some docker container
 type Context struct {
-	Name       string     `yaml:",omitempty"`
-	Variations Variations `yaml:"variations"`
-	Remove     *bool
-	Image      string
-	Build      DockerBuild
-	Env        map[string]string
-	Mounts     map[string]string
-	EntryPoint string `yaml:"entrypoint,omitempty"`
-	Cmd        []string
-	Network    string
-	Platform   string   `yaml:"platform,omitempty"`
-	DockerArgs []string `yaml:"dockerArgs,omitempty"`
+	Name        string     `yaml:",omitempty"`
+	Description string     `yaml:",omitempty"`
+	Extends     string     `yaml:"extends,omitempty"`
+	Variations  Variations `yaml:"variations"`
+	Remove      *bool
+	Image       string
+	Build       DockerBuild
+	Env         map[string]string
+	Mounts      map[string]string
+	EntryPoint  string `yaml:"entrypoint,omitempty"`
+	Cmd         []string
+	Network     string
+	Platform    string     `yaml:"platform,omitempty"`
+	User        string     `yaml:"user,omitempty"`
+	Runtime     string     `yaml:"runtime,omitempty"`
+	DockerArgs  []string   `yaml:"dockerArgs,omitempty"`
+	Exclude     [][]string `yaml:"exclude,omitempty"`
+	Requires    []string   `yaml:"requires,omitempty"`
+	Conflicts   []string   `yaml:"conflicts,omitempty"`
+	Inputs      []Input    `yaml:"inputs,omitempty"`
+
+	// unset indicates that this context was marked with `!unset` to remove it from its variation
+	unset bool
+
+	// removeUnset indicates that remove was marked with `!unset` to clear inherited value
+	removeUnset bool
 }
 
 // Clone returns a deep-copy of this context
@@ -50,6 +64,7 @@ func (c Context) Clone() Context {
 	for key, value := range c.Build.Args {
 		clone.Build.Args[key] = value
 	}
+	clone.Inputs = append([]Input(nil), c.Inputs...)
 	return clone
 }
 
@@ -59,6 +74,12 @@ func (c Context) Merge(source Context, withVariations bool) Context {
 	if source.Name != "" {
 		merged.Name = source.Name
 	}
+	if source.Description != "" {
+		merged.Description = source.Description
+	}
+	if source.Extends != "" {
+		merged.Extends = source.Extends
+	}
 	if withVariations {
 		if source.Variations != nil {
 			merged.Variations = merged.Variations.Merge(source.Variations)
@@ -69,6 +90,10 @@ func (c Context) Merge(source Context, withVariations bool) Context {
 	if source.Remove != nil {
 		value := *source.Remove
 		merged.Remove = &value
+		merged.removeUnset = false
+	} else if source.removeUnset {
+		merged.Remove = nil
+		merged.removeUnset = true
 	}
 	if source.Image != "" {
 		merged.Image = source.Image
@@ -76,6 +101,12 @@ func (c Context) Merge(source Context, withVariations bool) Context {
 	if source.Platform != "" {
 		merged.Platform = source.Platform
 	}
+	if source.User != "" {
+		merged.User = source.User
+	}
+	if source.Runtime != "" {
+		merged.Runtime = source.Runtime
+	}
 	for key, value := range source.Env {
 		merged.Env[key] = value
 	}
@@ -94,13 +125,23 @@ func (c Context) Merge(source Context, withVariations bool) Context {
 	if source.Network != "" {
 		merged.Network = source.Network
 	}
-	merged.Cmd = append(merged.Cmd, source.Cmd...)
-	merged.DockerArgs = append(merged.DockerArgs, source.DockerArgs...)
+	if source.EntryPoint != "" {
+		merged.EntryPoint = source.EntryPoint
+	}
+	merged.Cmd = mergeStrings(merged.Cmd, source.Cmd)
+	merged.DockerArgs = mergeStrings(merged.DockerArgs, source.DockerArgs)
+	merged.Exclude = mergeRules(merged.Exclude, source.Exclude)
+	merged.Requires = mergeStrings(merged.Requires, source.Requires)
+	merged.Conflicts = mergeStrings(merged.Conflicts, source.Conflicts)
+	merged.Inputs = mergeInputs(merged.Inputs, source.Inputs)
 	return merged
 }
 
 // GetContext returns context resulting from merging contexts with given names from all variations
 func (c Context) GetContext(names []string) (Context, error) {
+	if err := c.CheckConstraints(names); err != nil {
+		return Context{}, err
+	}
 	ctx, remainingNames, err := c.getContextRecursively(names)
 	if err != nil {
 		return Context{}, err
@@ -108,7 +149,14 @@ func (c Context) GetContext(names []string) (Context, error) {
 	if len(remainingNames) > 0 {
 		return Context{}, fmt.Errorf("extraneous context names: %s", strings.Join(remainingNames, " "))
 	}
+	ctx = ctx.withoutMarkers()
 	ctx.Name = strings.Join(names, " ")
+
+	// Descriptions and constraints are irrelevant once context is resolved
+	ctx.Description = ""
+	ctx.Exclude = nil
+	ctx.Requires = nil
+	ctx.Conflicts = nil
 	return ctx, nil
 }
 
@@ -126,21 +174,23 @@ func (c Context) getContextRecursively(names []string) (Context, []string, error
 		name := names[0]
 		names = names[1:]
 
-		// Merge variation context
-		variationContext, ok := variation.Contexts[name]
-		if !ok {
-			return Context{}, nil, fmt.Errorf("context %q not found in variation %q", name, variation.Name)
+		// Merge variation contexts (multiple ones for multi-select variations)
+		variationContexts, err := variation.getSelectedContexts(name)
+		if err != nil {
+			return Context{}, nil, err
 		}
-		ctx = ctx.Merge(variationContext, false)
-
-		// Get child contexts recursively
-		if len(variationContext.Variations) > 0 {
-			childContext, remainingNames, err := variationContext.getContextRecursively(names)
-			if err != nil {
-				return Context{}, nil, err
+		for _, variationContext := range variationContexts {
+			ctx = ctx.Merge(variationContext, false)
+
+			// Get child contexts recursively
+			if len(variationContext.Variations) > 0 {
+				childContext, remainingNames, err := variationContext.getContextRecursively(names)
+				if err != nil {
+					return Context{}, nil, err
+				}
+				ctx = ctx.Merge(childContext, false)
+				names = remainingNames
 			}
-			ctx = ctx.Merge(childContext, false)
-			names = remainingNames
 		}
 	}
 
@@ -161,7 +211,7 @@ func (c Context) GetAllImagesAndPlatforms(platform string) []ImageAndPlatform {
 		platform = c.Platform
 	}
 
-	if c.Image != "" {
+	if c.Image != "" && c.Image != unsetValue {
 		imagesAndPlatforms[c.Image] = ImageAndPlatform{
 			Image:    c.Image,
 			Platform: platform,
@@ -3,8 +3,7 @@ package yey
 import (
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,16 +13,45 @@ import (
 )
 
 const (
-	currentVersion = 0
-	yeyRCFileName  = ".yeyrc.yaml"
+	yeyRCFileName = ".yeyrc.yaml"
 )
 
 // ContextFile represents yey's current config persisted to disk
 type ContextFile struct {
-	Version int
-	Parent  string
-	Path    string `yaml:"-"`
-	Context `yaml:",inline"`
+	Version     int
+	Parent      ParentRef
+	Parents     []ParentRef
+	TrustedKeys []string            `yaml:"trustedKeys,omitempty"`
+	Cascade     bool                `yaml:",omitempty"`
+	Presets     map[string][]string `yaml:",omitempty"`
+	Templates   map[string]Context  `yaml:",omitempty"`
+	Path        string              `yaml:"-"`
+	Context     `yaml:",inline"`
+}
+
+// GetParents returns the ordered list of parents referenced by this context file,
+// whether they were specified via the single `parent` or the `parents` list property
+func (f ContextFile) GetParents() ([]ParentRef, error) {
+	if f.Parent.URI != "" && len(f.Parents) > 0 {
+		return nil, fmt.Errorf("cannot specify both %q and %q properties", "parent", "parents")
+	}
+	if f.Parent.URI != "" {
+		return []ParentRef{f.Parent}, nil
+	}
+	return f.Parents, nil
+}
+
+// HasChoicesFrom returns whether any context of this file, including templates, defines `choicesFrom`
+func (f ContextFile) HasChoicesFrom() bool {
+	if f.Context.HasChoicesFrom() {
+		return true
+	}
+	for _, template := range f.Templates {
+		if template.HasChoicesFrom() {
+			return true
+		}
+	}
+	return false
 }
 
 // readContextFileFromWorkingDirectory scans the current directory and searches for a .yeyrc.yaml file and returns
@@ -84,90 +112,267 @@ func readContextFileFromFilePath(path string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
-// readContextFileFromNetwork reads the contextfile from the network over http
-func readContextFileFromNetwork(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed fetching context file from network: %w", err)
+// parseContextFile unmarshals the contextFile data and resolves any parent contextfiles. The uri is the
+// path or URL the data was read from (or empty if unknown) and is used for resolving relative paths and
+// parents, while chain is the list of context file URIs currently being resolved (for cycle detection).
+// Only trusted context files (local or remote ones pinned or signed) may run `choicesFrom` commands on host.
+func parseContextFile(uri string, data []byte, chain []string, trusted bool) (Contexts, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return Contexts{}, fmt.Errorf("failed to decode context file: %w", err)
+	}
+	if _, err := migrate(&node); err != nil {
+		return Contexts{}, err
+	}
+	resolveMergeTags(&node)
+	if diagnostics := validateContextFileNode(uri, &node); len(diagnostics) > 0 {
+		if IsStrict {
+			return Contexts{}, fmt.Errorf("invalid context file:\n%w", diagnostics)
+		}
+		for _, diagnostic := range diagnostics {
+			Warn("%s", diagnostic)
+		}
 	}
-	defer resp.Body.Close()
-
-	return io.ReadAll(resp.Body)
-}
 
-// parseContextFile unmarshals the contextFile data and resolves any parent contextfiles
-func parseContextFile(dir string, data []byte) (Contexts, error) {
-	var ctxFile ContextFile
-	if err := yaml.Unmarshal(data, &ctxFile); err != nil {
+	// Empty documents have nothing to migrate and are therefore considered up-to-date
+	ctxFile := ContextFile{Version: currentVersion}
+	removeUnset := takeUnsetEntry(&node, removeProperty)
+	templatesRemoveUnset := takeTemplatesUnsetEntries(&node, removeProperty)
+	if err := node.Decode(&ctxFile); err != nil {
 		return Contexts{}, fmt.Errorf("failed to decode context file: %w", err)
 	}
+	ctxFile.Context.removeUnset = removeUnset
+	for name, template := range ctxFile.Templates {
+		template.removeUnset = templatesRemoveUnset[name]
+		ctxFile.Templates[name] = template
+	}
 
 	if ctxFile.Version != currentVersion {
 		return Contexts{}, fmt.Errorf("unsupported context file version")
 	}
 
+	if !trusted && ctxFile.HasChoicesFrom() {
+		return Contexts{}, fmt.Errorf("refusing to run %s commands of context file %q: remote context files must be pinned with sha256 or signed", choicesFromProperty, uri)
+	}
+
+	context, err := expandContext(ctxFile.Context, "")
+	if err != nil {
+		return Contexts{}, fmt.Errorf("failed to expand environment variables in context file %q: %w", uri, err)
+	}
+	templates := make(map[string]Context, len(ctxFile.Templates))
+	for name, template := range ctxFile.Templates {
+		templates[name], err = expandContext(template, "templates."+name)
+		if err != nil {
+			return Contexts{}, fmt.Errorf("failed to expand environment variables in context file %q: %w", uri, err)
+		}
+	}
 	contexts := Contexts{
-		Context: ctxFile.Context,
+		Presets:   ctxFile.Presets,
+		Templates: templates,
+		Context:   context,
 	}
 
-	if dir != "" {
+	if uri != "" && !isRemote(uri) {
 		var err error
-		contexts, err = resolveContextsPaths(dir, contexts)
+		contexts, err = resolveContextsPaths(filepath.Dir(uri), contexts)
 		if err != nil {
 			return Contexts{}, err
 		}
 	}
 
-	if ctxFile.Parent != "" {
-		parent, err := readAndParseContextFileFromURI(ctxFile.Parent)
+	parents, err := ctxFile.GetParents()
+	if err != nil {
+		return Contexts{}, err
+	}
+	if len(parents) > 0 {
+		if uri != "" {
+			chain = append(chain[:len(chain):len(chain)], uri)
+		}
+		parent, err := readAndMergeParents(uri, parents, chain)
 		if err != nil {
-			return Contexts{}, fmt.Errorf("failed to resolve parent context %q: %w", ctxFile.Parent, err)
+			return Contexts{}, err
 		}
 		contexts = parent.Merge(contexts)
 	}
 
-	contexts = resolveEnvironmentVariables(contexts)
-
 	return contexts, nil
 }
 
-// readAndParseContextFileFromURI reads and parses the context file from an URI, which can either
-// be an URL or local path
-func readAndParseContextFileFromURI(path string) (Contexts, error) {
-	Log("loading context file: %s", path)
+// readAndMergeParents reads and parses all given parent context files and merges them
+// left-to-right, so that each parent overrides the ones preceding it
+func readAndMergeParents(baseURI string, refs []ParentRef, chain []string) (Contexts, error) {
+	var merged Contexts
+	for i, ref := range refs {
+		var err error
+		ref.URI, err = resolveURI(baseURI, ref.URI)
+		if err != nil {
+			return Contexts{}, fmt.Errorf("failed to resolve parent context %q: %w", ref, err)
+		}
+		if ref.Signature != "" {
+			ref.Signature, err = resolveURI(baseURI, ref.Signature)
+			if err != nil {
+				return Contexts{}, fmt.Errorf("failed to resolve signature %q: %w", ref.Signature, err)
+			}
+		}
 
-	var bytes []byte
-	var err error
-	var dir string
+		// Detect cycles
+		for _, uri := range chain {
+			if uri == ref.URI {
+				return Contexts{}, fmt.Errorf("parent context cycle detected: %s", strings.Join(append(chain, ref.URI), " -> "))
+			}
+		}
 
-	if strings.HasPrefix(path, "https:") || strings.HasPrefix(path, "http:") {
-		bytes, err = readContextFileFromNetwork(path)
-	} else {
-		dir = filepath.Dir(path)
-		bytes, err = readContextFileFromFilePath(path)
+		parent, err := readAndParseContextFileFromURI(ref, chain)
+		if err != nil {
+			return Contexts{}, fmt.Errorf("failed to resolve parent context %q: %w", ref, err)
+		}
+		if i == 0 {
+			merged = parent
+		} else {
+			merged = merged.Merge(parent)
+		}
+	}
+	return merged, nil
+}
+
+// isURL returns whether given URI refers to a file accessed over http
+func isURL(uri string) bool {
+	return strings.HasPrefix(uri, "https:") || strings.HasPrefix(uri, "http:")
+}
+
+// isRemote returns whether given URI refers to a remote file rather than a local path
+func isRemote(uri string) bool {
+	return isURL(uri) || isGitURI(uri)
+}
+
+// resolveURI resolves given URI relatively to the URI of the context file referencing it, which
+// can either be an URL or a local path
+func resolveURI(baseURI, uri string) (string, error) {
+	if isRemote(uri) {
+		return uri, nil
+	}
+
+	if isGitURI(baseURI) && !filepath.IsAbs(uri) && !strings.HasPrefix(uri, "~") {
+		base, err := parseGitURI(baseURI)
+		if err != nil {
+			return "", err
+		}
+		return base.resolve(uri).String(), nil
+	}
+
+	if isURL(baseURI) {
+		base, err := url.Parse(baseURI)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(uri)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
 	}
 
+	dir := "."
+	if baseURI != "" {
+		dir = filepath.Dir(baseURI)
+	}
+	path, err := resolvePath(dir, uri)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// readContextFileFromURI reads the context file from an URI, which can either be an URL, a git URI or
+// local path
+func readContextFileFromURI(uri string) ([]byte, error) {
+	if isURL(uri) {
+		return readContextFileFromNetwork(uri)
+	}
+	if isGitURI(uri) {
+		return readContextFileFromGit(uri)
+	}
+	return readContextFileFromFilePath(uri)
+}
+
+// readAndParseContextFileFromURI reads and parses the context file referenced by given parent,
+// after verifying its integrity and signature, if any
+func readAndParseContextFileFromURI(ref ParentRef, chain []string) (Contexts, error) {
+	Log("loading context file: %s", ref)
+
+	bytes, err := readContextFileFromURI(ref.URI)
 	if err != nil {
 		return Contexts{}, fmt.Errorf("failed to read context file: %w", err)
 	}
 
-	return parseContextFile(dir, bytes)
+	if err := ref.verifyIntegrity(bytes); err != nil {
+		return Contexts{}, fmt.Errorf("refusing to use context file %q: %w", ref, err)
+	}
+	if err := ref.verifySignature(bytes); err != nil {
+		return Contexts{}, fmt.Errorf("refusing to use context file %q: %w", ref, err)
+	}
+
+	return parseContextFile(ref.URI, bytes, chain, ref.isTrusted())
+}
+
+// FindContextFilePath returns the path of the context file that would be used from current
+// working directory
+func FindContextFilePath() (string, error) {
+	_, path, err := readContextFileFromWorkingDirectory()
+	return path, err
 }
 
 // LoadContexts reads the context file and returns the contexts. It starts by reading from current
-// working directory and resolves all parent context files.
+// working directory and resolves all parent context files. Choices of variations defining `choicesFrom`
+// are not generated, so that no command is run on host.
 func LoadContexts() (Contexts, error) {
+	return loadContextsFromWorkingDirectory(false)
+}
+
+// LoadContextsWithChoices is similar to LoadContexts, but also generates choices of variations defining
+// `choicesFrom`, by running their commands on host
+func LoadContextsWithChoices() (Contexts, error) {
+	return loadContextsFromWorkingDirectory(true)
+}
+
+func loadContextsFromWorkingDirectory(withChoices bool) (Contexts, error) {
 	bytes, path, err := readContextFileFromWorkingDirectory()
 	if err != nil {
 		return Contexts{}, fmt.Errorf("failed to read context file: %w", err)
 	}
 
-	Log("loading context file: %s", path)
-	contexts, err := parseContextFile(filepath.Dir(path), bytes)
+	var contexts Contexts
+	if isCascading(bytes) {
+		paths, err := getCascadingContextFilePaths(filepath.Dir(path))
+		if err != nil {
+			return Contexts{}, err
+		}
+		contexts, err = loadCascadingContexts(paths)
+		if err != nil {
+			return Contexts{}, err
+		}
+	} else {
+		Log("loading context file: %s", path)
+		contexts, err = parseContextFile(path, bytes, nil, true)
+		if err != nil {
+			return Contexts{}, err
+		}
+	}
+	contexts.Path = path
+	contexts.Variations = contexts.Variations.prune()
+
+	// Base runtime is used as is by commands that are not tied to a specific context
+	contexts.Runtime = stringWithoutMarker(contexts.Runtime)
+	if withChoices {
+		contexts.Variations, err = contexts.Variations.resolveChoices()
+		if err != nil {
+			return Contexts{}, err
+		}
+	}
+	contexts, err = contexts.resolveExtends()
 	if err != nil {
 		return Contexts{}, err
 	}
-	contexts.Path = path
 
 	return contexts, nil
 }
@@ -178,6 +383,12 @@ func resolveContextsPaths(dir string, contexts Contexts) (Contexts, error) {
 	if err != nil {
 		return Contexts{}, err
 	}
+	for name, template := range contexts.Templates {
+		contexts.Templates[name], err = resolveContextPaths(dir, template)
+		if err != nil {
+			return Contexts{}, err
+		}
+	}
 	for _, variation := range contexts.Variations {
 		for name, context := range variation.Contexts {
 			variation.Contexts[name], err = resolveContextPaths(dir, context)
@@ -185,6 +396,12 @@ func resolveContextsPaths(dir string, contexts Contexts) (Contexts, error) {
 				return Contexts{}, err
 			}
 		}
+		if variation.ChoicesFrom != nil {
+			variation.ChoicesFrom.Template, err = resolveContextPaths(dir, variation.ChoicesFrom.Template)
+			if err != nil {
+				return Contexts{}, err
+			}
+		}
 	}
 	return contexts, nil
 }
@@ -219,8 +436,9 @@ func resolveContextPaths(dir string, context Context) (Context, error) {
 }
 
 func resolvePath(dir, path string) (string, error) {
-	if path == "" {
-		return "", nil
+	// Unset values must be kept as is, until stripped once context is resolved
+	if path == "" || path == unsetValue {
+		return path, nil
 	}
 
 	// Resolve home dir
@@ -240,23 +458,3 @@ func resolvePath(dir, path string) (string, error) {
 
 	return filepath.Join(dir, path), nil
 }
-
-func resolveEnvironmentVariables(contexts Contexts) Contexts {
-	clone := contexts
-	clone.Context = clone.Context.Clone()
-	clone.Variations = clone.Variations.Clone()
-
-	for key, value := range clone.Env {
-		clone.Context.Env[key] = os.ExpandEnv(value)
-	}
-
-	for _, variation := range clone.Variations {
-		for _, ctx := range variation.Contexts {
-			for key, value := range ctx.Env {
-				ctx.Env[key] = os.ExpandEnv(value)
-			}
-		}
-	}
-
-	return clone
-}
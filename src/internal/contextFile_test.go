@@ -0,0 +1,176 @@
+package yey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestParseContextFileWithMultipleParents(t *testing.T) {
+	dir := t.TempDir()
+	org := writeFile(t, dir, "org.yaml", `
+image: org_image
+env:
+  ORG: org
+  TEAM: org
+  PROJECT: org
+variations:
+  env:
+    dev:
+      env:
+        ENV: org_dev
+`)
+	team := writeFile(t, dir, "team.yaml", `
+env:
+  TEAM: team
+  PROJECT: team
+variations:
+  env:
+    dev:
+      env:
+        ENV: team_dev
+    prod:
+      env:
+        ENV: team_prod
+`)
+
+	contexts, err := parseContextFile(filepath.Join(dir, yeyRCFileName), []byte(`
+parents:
+  - `+org+`
+  - `+team+`
+env:
+  PROJECT: project
+`), nil, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, "org_image", contexts.Image)
+	assert.Equal(t, map[string]string{"ORG": "org", "TEAM": "team", "PROJECT": "project"}, contexts.Env)
+
+	dev, err := contexts.GetContext([]string{"dev"})
+	require.NoError(t, err)
+	assert.Equal(t, "team_dev", dev.Env["ENV"])
+
+	prod, err := contexts.GetContext([]string{"prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "team_prod", prod.Env["ENV"])
+}
+
+func TestParseContextFileWithBothParentAndParents(t *testing.T) {
+	_, err := parseContextFile("", []byte(`
+parent: a.yaml
+parents: [b.yaml]
+`), nil, true)
+	assert.EqualError(t, err, `failed to migrate context file from version 0 to 1: cannot specify both "parent" and "parents" properties`)
+}
+
+func TestParseContextFileResolvesParentRelativeToReferencingFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "shared/org.yaml", "image: org_image\n")
+	writeFile(t, dir, "shared/team.yaml", "parent: org.yaml\nenv:\n  TEAM: team\n")
+
+	contexts, err := parseContextFile(filepath.Join(dir, "project", yeyRCFileName), []byte("parent: ../shared/team.yaml\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "org_image", contexts.Image)
+	assert.Equal(t, "team", contexts.Env["TEAM"])
+}
+
+func TestParseContextFileResolvesParentRelativeToURL(t *testing.T) {
+	withCacheDir(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/configs/team.yaml":
+			w.Write([]byte("parent: ../org.yaml\nenv:\n  TEAM: team\n"))
+		case "/org.yaml":
+			w.Write([]byte("image: org_image\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	contexts, err := parseContextFile("", []byte("parent: "+server.URL+"/configs/team.yaml\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "org_image", contexts.Image)
+	assert.Equal(t, "team", contexts.Env["TEAM"])
+}
+
+func TestParseContextFileDetectsParentCycles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.yaml", "parent: b.yaml\n")
+	b := writeFile(t, dir, "b.yaml", "parent: a.yaml\n")
+
+	_, err := readAndParseContextFileFromURI(ParentRef{URI: a}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parent context cycle detected: "+a+" -> "+b+" -> "+a)
+}
+
+func TestParseVariationDefault(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", `
+variations:
+  env:
+    default: dev
+    dev: {}
+    prod: {}
+  region:
+    default: east
+    east: {}
+`)
+
+	contexts, err := parseContextFile("", []byte(`
+parent: `+parent+`
+variations:
+  env:
+    default: prod
+`), nil, true)
+	require.NoError(t, err)
+
+	env, ok := contexts.Variations.GetByName("env")
+	require.True(t, ok)
+	assert.Equal(t, "prod", env.Default)
+	assert.Len(t, env.Contexts, 2)
+
+	region, ok := contexts.Variations.GetByName("region")
+	require.True(t, ok)
+	assert.Equal(t, "east", region.Default)
+}
+
+func TestParseVariationPreservesDeclarationOrder(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", `
+variations:
+  env:
+    prod:
+      description: Production
+    dev: {}
+`)
+
+	contexts, err := parseContextFile("", []byte(`
+parent: `+parent+`
+variations:
+  env:
+    stg:
+      description: Staging
+    dev:
+      description: Development
+`), nil, true)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"prod", "dev", "stg"}, contexts.Variations[0].GetNames())
+	assert.Equal(t, [][]string{{"prod"}, {"dev"}, {"stg"}}, contexts.GetCombos())
+	assert.Equal(t, []string{"Development"}, contexts.GetDescriptions([]string{"dev"}))
+}
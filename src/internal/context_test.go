@@ -8,11 +8,12 @@ import (
 
 	"github.com/go-test/deep"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func loadContext(file string) Context {
 	path := filepath.Join("testdata", file+".yaml")
-	contexts, err := readAndParseContextFileFromURI(path)
+	contexts, err := readAndParseContextFileFromURI(ParentRef{URI: path}, nil)
 	if err != nil {
 		panic(fmt.Errorf("loading context from %q: %w", path, err))
 	}
@@ -67,6 +68,10 @@ func TestMerge(t *testing.T) {
 			parent: "ctx1",
 			child:  "ctx1b",
 		},
+		{
+			parent: "runtime1",
+			child:  "runtime1b",
+		},
 	}
 
 	for _, c := range cases {
@@ -135,3 +140,37 @@ func getCtx1() Context {
 		},
 	}
 }
+
+func TestGetContextWithMultiVariation(t *testing.T) {
+	contexts, err := parseContextFile("", []byte(`
+image: base
+variations:
+  tools:
+    multi: true
+    default: aws+node
+    aws:
+      env:
+        AWS: "true"
+      mounts:
+        ~/.aws: /root/.aws
+    gcp:
+      env:
+        GCP: "true"
+    node:
+      image: node
+      env:
+        AWS: "false"
+`), nil, true)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext([]string{"aws+node"})
+	require.NoError(t, err)
+	assert.Equal(t, "node", ctx.Image)
+	assert.Equal(t, map[string]string{"AWS": "false"}, ctx.Env)
+	assert.Len(t, ctx.Mounts, 1)
+
+	assert.Equal(t, []bool{true}, contexts.GetDefaults([]string{"aws+node"}))
+
+	_, err = contexts.GetContext([]string{"aws+aws"})
+	assert.EqualError(t, err, `context "aws" selected multiple times in variation "tools"`)
+}
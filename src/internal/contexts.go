@@ -1,14 +1,50 @@
 package yey
 
+import (
+	"sort"
+)
+
 // Contexts represents a combinaison of base and named contexts
 type Contexts struct {
-	Path string
+	Path      string
+	Presets   map[string][]string
+	Templates map[string]Context
 	Context
 }
 
 // Merge creates a deep-copy of this object and copies values from given source object on top of it
 func (c Contexts) Merge(source Contexts) Contexts {
+	presets := make(map[string][]string, len(c.Presets)+len(source.Presets))
+	for name, names := range c.Presets {
+		presets[name] = names
+	}
+	for name, names := range source.Presets {
+		presets[name] = names
+	}
+	templates := make(map[string]Context, len(c.Templates)+len(source.Templates))
+	for name, template := range c.Templates {
+		templates[name] = template.Clone()
+	}
+	for name, template := range source.Templates {
+		if existing, ok := templates[name]; ok {
+			templates[name] = existing.Merge(template, true)
+		} else {
+			templates[name] = template.Clone()
+		}
+	}
 	return Contexts{
-		Context: c.Context.Merge(source.Context, true),
+		Presets:   presets,
+		Templates: templates,
+		Context:   c.Context.Merge(source.Context, true),
+	}
+}
+
+// GetPresetNames returns the sorted list of preset names
+func (c Contexts) GetPresetNames() []string {
+	names := make([]string, 0, len(c.Presets))
+	for name := range c.Presets {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
@@ -75,3 +75,23 @@ func TestGetContext(t *testing.T) {
 		})
 	}
 }
+
+func TestMergePresets(t *testing.T) {
+	parent := Contexts{Presets: map[string][]string{
+		"pgo": {"prod", "go"},
+		"dgo": {"dev", "go"},
+	}}
+	child := Contexts{Presets: map[string][]string{
+		"pgo":   {"prod", "us-east1", "go"},
+		"pnode": {"prod", "node"},
+	}}
+
+	merged := parent.Merge(child)
+
+	_assert.Equal(t, map[string][]string{
+		"pgo":   {"prod", "us-east1", "go"},
+		"dgo":   {"dev", "go"},
+		"pnode": {"prod", "node"},
+	}, merged.Presets)
+	_assert.Equal(t, []string{"dgo", "pgo", "pnode"}, merged.GetPresetNames())
+}
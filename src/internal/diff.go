@@ -0,0 +1,66 @@
+package yey
+
+import "strings"
+
+// Prefixes of lines returned by DiffLines
+const (
+	DiffUnchanged = "  "
+	DiffRemoved   = "- "
+	DiffAdded     = "+ "
+)
+
+// DiffLines returns the lines of old and new texts, each prefixed with DiffUnchanged, DiffRemoved
+// or DiffAdded, based on their longest common subsequence of lines
+func DiffLines(old, new string) []string {
+	oldLines := textLines(old)
+	newLines := textLines(new)
+
+	// lengths[i][j] is the length of longest common subsequence of oldLines[i:] and newLines[j:]
+	lengths := make([][]int, len(oldLines)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			lines = append(lines, DiffUnchanged+oldLines[i])
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			lines = append(lines, DiffRemoved+oldLines[i])
+			i++
+		default:
+			lines = append(lines, DiffAdded+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		lines = append(lines, DiffRemoved+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		lines = append(lines, DiffAdded+newLines[j])
+	}
+	return lines
+}
+
+// textLines returns the lines of given text, ignoring trailing newline
+func textLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
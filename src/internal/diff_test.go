@@ -0,0 +1,50 @@
+package yey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Old      string
+		New      string
+		Expected []string
+	}{
+		{
+			Name:     "both empty",
+			Expected: nil,
+		},
+		{
+			Name:     "identical",
+			Old:      "image: alpine\nenv:\n  A: 1\n",
+			New:      "image: alpine\nenv:\n  A: 1\n",
+			Expected: []string{"  image: alpine", "  env:", "    A: 1"},
+		},
+		{
+			Name:     "changed value",
+			Old:      "image: alpine\nenv:\n  A: 1\n  B: 2\n",
+			New:      "image: alpine\nenv:\n  A: 3\n  B: 2\n",
+			Expected: []string{"  image: alpine", "  env:", "-   A: 1", "+   A: 3", "    B: 2"},
+		},
+		{
+			Name:     "added and removed lines",
+			Old:      "image: alpine\nremove: true\n",
+			New:      "image: alpine\nnetwork: host\n",
+			Expected: []string{"  image: alpine", "- remove: true", "+ network: host"},
+		},
+		{
+			Name:     "from empty",
+			New:      "image: alpine\n",
+			Expected: []string{"+ image: alpine"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			assert.Equal(t, c.Expected, DiffLines(c.Old, c.New))
+		})
+	}
+}
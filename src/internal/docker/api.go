@@ -0,0 +1,694 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	yey "github.com/silphid/yey/src/internal"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// defaultDockerHost is the address of docker daemon when DOCKER_HOST is not set
+	defaultDockerHost = "unix:///var/run/docker.sock"
+
+	// apiBaseURL is the base URL of API requests, which are sent to docker daemon regardless of host
+	apiBaseURL = "http://docker"
+)
+
+// apiRuntime is the runtime talking to docker daemon through the Docker Engine API
+type apiRuntime struct {
+	client *http.Client
+	dial   func(ctx context.Context) (net.Conn, error)
+
+	// cli is the fallback for features not supported through API
+	cli Runtime
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// newAPIRuntime returns a runtime connecting to docker daemon at given address, in DOCKER_HOST format
+// (defaults to docker's unix socket)
+func newAPIRuntime(host string) (*apiRuntime, error) {
+	if host == "" {
+		host = defaultDockerHost
+	}
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: %w", host, err)
+	}
+
+	var network, address string
+	switch hostURL.Scheme {
+	case "unix":
+		network, address = "unix", hostURL.Path
+	case "tcp":
+		if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			return nil, fmt.Errorf("TLS connections to docker daemon are not supported")
+		}
+		network, address = "tcp", hostURL.Host
+	default:
+		return nil, fmt.Errorf("unsupported DOCKER_HOST scheme %q", hostURL.Scheme)
+	}
+
+	dialer := &net.Dialer{}
+	dial := func(ctx context.Context) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	return &apiRuntime{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dial(ctx)
+				},
+			},
+		},
+		dial:   dial,
+		cli:    cliRuntimes[DockerRuntime],
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}, nil
+}
+
+// apiError represents an error response of docker daemon
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// isNotFound returns whether given error is a `not found` response of docker daemon
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// readAPIError returns the error described by given error response
+func readAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Message == "" {
+		body.Message = strings.TrimSpace(string(data))
+		if body.Message == "" {
+			body.Message = resp.Status
+		}
+	}
+	return &apiError{StatusCode: resp.StatusCode, Message: body.Message}
+}
+
+func (r *apiRuntime) newRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	requestURL := apiBaseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	yey.Log("docker API: %s %s", method, req.URL.RequestURI())
+	return req, nil
+}
+
+// do sends given request, returning an error for error responses
+func (r *apiRuntime) do(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	req, err := r.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp, nil
+}
+
+// call sends given request and decodes its JSON response into result, unless it is nil
+func (r *apiRuntime) call(ctx context.Context, method, path string, query url.Values, body interface{}, result interface{}) error {
+	resp, err := r.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// hijack sends given request and upgrades its connection to a raw bidirectional stream, as used for
+// attaching to containers
+func (r *apiRuntime) hijack(ctx context.Context, method, path string, query url.Values, body interface{}) (net.Conn, io.Reader, error) {
+	req, err := r.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, readAPIError(resp)
+	}
+	return conn, reader, nil
+}
+
+// Ping returns an error if docker daemon cannot be reached
+func (r *apiRuntime) Ping(ctx context.Context) error {
+	if err := r.call(ctx, http.MethodGet, "/_ping", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to reach docker daemon: %w", err)
+	}
+	return nil
+}
+
+func containerPath(name string) string {
+	return "/containers/" + url.PathEscape(name)
+}
+
+// containerInspect is the subset of container details used by yey
+type containerInspect struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status string
+	}
+	Config struct {
+		Tty    bool
+		Labels map[string]string
+	}
+	HostConfig struct {
+		AutoRemove bool
+	}
+}
+
+func (r *apiRuntime) inspectContainer(ctx context.Context, name string) (containerInspect, error) {
+	var info containerInspect
+	err := r.call(ctx, http.MethodGet, containerPath(name)+"/json", nil, nil, &info)
+	return info, err
+}
+
+func (r *apiRuntime) GetContainerStatus(ctx context.Context, name string) (string, error) {
+	info, err := r.inspectContainer(ctx, name)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get container status: %w", err)
+	}
+	return info.State.Status, nil
+}
+
+func (r *apiRuntime) GetContainerLabel(ctx context.Context, name, label string) (string, error) {
+	info, err := r.inspectContainer(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %q: %w", name, err)
+	}
+	return info.Config.Labels[label], nil
+}
+
+func (r *apiRuntime) ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	return r.listContainers(ctx, all, map[string][]string{"label": {yey.LabelPath}}, false)
+}
+
+func (r *apiRuntime) ListLegacyContainers(ctx context.Context, all bool) ([]Container, error) {
+	return r.listContainers(ctx, all, map[string][]string{"name": {containerNamePrefix}}, true)
+}
+
+// listContainers returns the containers matching given filters, which are either labeled containers or,
+// when legacy is true, unlabeled containers created by older versions of yey
+func (r *apiRuntime) listContainers(ctx context.Context, all bool, filters map[string][]string, legacy bool) ([]Container, error) {
+	data, err := json.Marshal(filters)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{"filters": {string(data)}}
+	if all {
+		query.Set("all", "1")
+	}
+
+	var items []struct {
+		Names  []string
+		Labels map[string]string
+	}
+	if err := r.call(ctx, http.MethodGet, "/containers/json", query, nil, &items); err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := []Container{}
+	for _, item := range items {
+		if len(item.Names) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(item.Names[0], "/")
+		if legacy {
+			// Name filter also matches containers merely containing prefix in their name
+			if isLegacyContainer(name, item.Labels) {
+				containers = append(containers, Container{Name: name, Legacy: true})
+			}
+			continue
+		}
+		container, err := newContainer(name, item.Labels)
+		if err != nil {
+			yey.Warn("ignoring container with invalid labels: %v", err)
+			continue
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+func (r *apiRuntime) ImageExists(ctx context.Context, tag string) (bool, error) {
+	err := r.call(ctx, http.MethodGet, "/images/"+tag+"/json", nil, nil, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Pull pulls given image through API, falling back to docker CLI (which supports credential helpers)
+// upon failure
+func (r *apiRuntime) Pull(ctx context.Context, image, platform string) error {
+	if err := r.pull(ctx, image, platform); err != nil {
+		yey.Log("failed to pull image %q through docker API, falling back to docker CLI: %v", image, err)
+		return r.cli.Pull(ctx, image, platform)
+	}
+	return nil
+}
+
+func (r *apiRuntime) pull(ctx context.Context, image, platform string) error {
+	name, tag := splitImageTag(image)
+	query := url.Values{"fromImage": {name}, "tag": {tag}}
+	if platform != "" {
+		query.Set("platform", platform)
+	}
+	resp, err := r.do(ctx, http.MethodPost, "/images/create", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Display progress messages, except for intermediate download/extraction progress
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			ID       string `json:"id"`
+			Status   string `json:"status"`
+			Progress string `json:"progress"`
+			Error    string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case message.Error != "":
+			return errors.New(message.Error)
+		case message.Progress != "":
+			continue
+		case message.ID != "":
+			fmt.Fprintf(r.stderr, "%s: %s\n", message.ID, message.Status)
+		default:
+			fmt.Fprintln(r.stderr, message.Status)
+		}
+	}
+}
+
+// splitImageTag splits given image reference into name and tag (or digest), defaulting to `latest` tag
+func splitImageTag(image string) (string, string) {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// Build delegates to docker CLI, which takes care of sending build context to daemon
+func (r *apiRuntime) Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs map[string]string, context string) error {
+	return r.cli.Build(ctx, dockerPath, imageTag, platform, buildArgs, context)
+}
+
+// containerConfig is the body of container creation requests
+type containerConfig struct {
+	Image        string
+	Cmd          []string          `json:",omitempty"`
+	Entrypoint   []string          `json:",omitempty"`
+	Env          []string          `json:",omitempty"`
+	Labels       map[string]string `json:",omitempty"`
+	WorkingDir   string            `json:",omitempty"`
+	User         string            `json:",omitempty"`
+	Tty          bool
+	OpenStdin    bool
+	StdinOnce    bool
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	HostConfig   hostConfig
+}
+
+type hostConfig struct {
+	Binds       []string `json:",omitempty"`
+	NetworkMode string
+	AutoRemove  bool
+}
+
+// newContainerConfig returns the configuration of container to create for given context, equivalent
+// to the arguments passed to `docker run` by CLI runtime
+func newContainerConfig(yeyCtx yey.Context, cwd string, tty bool, options RunOptions) containerConfig {
+	env := []string{"YEY_WORK_DIR=" + cwd, "YEY_CONTEXT=" + yeyCtx.Name}
+	var contextEnv []string
+	for name, value := range yeyCtx.Env {
+		contextEnv = append(contextEnv, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(contextEnv)
+
+	var binds []string
+	for key, value := range yeyCtx.Mounts {
+		binds = append(binds, fmt.Sprintf("%s:%s", key, value))
+	}
+	sort.Strings(binds)
+
+	config := containerConfig{
+		Image:        yeyCtx.Image,
+		Cmd:          yeyCtx.Cmd,
+		Env:          append(env, contextEnv...),
+		Labels:       options.Labels,
+		WorkingDir:   options.WorkDir,
+		User:         yeyCtx.User,
+		Tty:          tty,
+		OpenStdin:    true,
+		StdinOnce:    true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		HostConfig: hostConfig{
+			Binds:       binds,
+			NetworkMode: getNetwork(yeyCtx, cliRuntimes[DockerRuntime].defaultNetwork),
+			AutoRemove:  yeyCtx.Remove != nil && *yeyCtx.Remove,
+		},
+	}
+	if yeyCtx.EntryPoint != "" {
+		config.Entrypoint = []string{yeyCtx.EntryPoint}
+	}
+	return config
+}
+
+func (r *apiRuntime) RunContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error {
+	// Arbitrary docker args can only be passed to CLI
+	if len(yeyCtx.DockerArgs) > 0 {
+		yey.Log("using docker CLI to pass dockerArgs")
+		return r.cli.RunContainer(ctx, yeyCtx, name, options)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	_, tty := r.getTerminal()
+	config := newContainerConfig(yeyCtx, cwd, tty, options)
+	query := url.Values{"name": {name}}
+	if yeyCtx.Platform != "" {
+		query.Set("platform", yeyCtx.Platform)
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = r.call(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	if isNotFound(err) {
+		// Pull missing image, as docker CLI does
+		if err := r.Pull(ctx, yeyCtx.Image, yeyCtx.Platform); err != nil {
+			return err
+		}
+		err = r.call(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create container %q: %w", name, err)
+	}
+
+	return r.attachAndStart(ctx, created.ID, config.Tty, config.HostConfig.AutoRemove)
+}
+
+func (r *apiRuntime) StartContainer(ctx context.Context, name string, options RunOptions) error {
+	if options.Detach {
+		if err := r.call(ctx, http.MethodPost, containerPath(name)+"/start", nil, nil, nil); err != nil {
+			return fmt.Errorf("failed to start container %q: %w", name, err)
+		}
+		return nil
+	}
+
+	info, err := r.inspectContainer(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %q: %w", name, err)
+	}
+	return r.attachAndStart(ctx, info.ID, info.Config.Tty, info.HostConfig.AutoRemove)
+}
+
+// attachAndStart attaches to given container, starts it and streams its input/output until it exits
+func (r *apiRuntime) attachAndStart(ctx context.Context, id string, tty, autoRemove bool) error {
+	// Attach before starting, so that no output gets missed
+	query := url.Values{"stream": {"1"}, "stdin": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	conn, reader, err := r.hijack(ctx, http.MethodPost, containerPath(id)+"/attach", query, nil)
+	if err != nil {
+		return fmt.Errorf("failed to attach to container: %w", err)
+	}
+	defer conn.Close()
+
+	// Wait for exit, which must also be requested before starting
+	condition := "next-exit"
+	if autoRemove {
+		condition = "removed"
+	}
+	waitResp, err := r.do(ctx, http.MethodPost, containerPath(id)+"/wait", url.Values{"condition": {condition}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to wait for container: %w", err)
+	}
+	defer waitResp.Body.Close()
+
+	if err := r.call(ctx, http.MethodPost, containerPath(id)+"/start", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	resize := func(height, width int) error {
+		query := url.Values{"h": {strconv.Itoa(height)}, "w": {strconv.Itoa(width)}}
+		return r.call(ctx, http.MethodPost, containerPath(id)+"/resize", query, nil, nil)
+	}
+	if err := r.stream(conn, reader, tty, resize); err != nil {
+		return err
+	}
+
+	var result struct {
+		StatusCode int
+		Error      *struct {
+			Message string
+		}
+	}
+	if err := json.NewDecoder(waitResp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to wait for container: %w", err)
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		return fmt.Errorf("failed to wait for container: %s", result.Error.Message)
+	}
+	return newExitError(result.StatusCode)
+}
+
+// execConfig is the body of exec creation requests
+type execConfig struct {
+	Cmd          []string
+	WorkingDir   string `json:",omitempty"`
+	Tty          bool
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+}
+
+func (r *apiRuntime) ExecContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error {
+	_, tty := r.getTerminal()
+	config := execConfig{
+		Cmd:          getExecCmd(yeyCtx),
+		WorkingDir:   options.WorkDir,
+		Tty:          tty,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := r.call(ctx, http.MethodPost, containerPath(name)+"/exec", nil, config, &created); err != nil {
+		return fmt.Errorf("failed to execute shell in container %q: %w", name, err)
+	}
+	execPath := "/exec/" + url.PathEscape(created.ID)
+
+	start := map[string]bool{"Detach": false, "Tty": tty}
+	conn, reader, err := r.hijack(ctx, http.MethodPost, execPath+"/start", nil, start)
+	if err != nil {
+		return fmt.Errorf("failed to attach to shell in container %q: %w", name, err)
+	}
+	defer conn.Close()
+
+	resize := func(height, width int) error {
+		query := url.Values{"h": {strconv.Itoa(height)}, "w": {strconv.Itoa(width)}}
+		return r.call(ctx, http.MethodPost, execPath+"/resize", query, nil, nil)
+	}
+	if err := r.stream(conn, reader, tty, resize); err != nil {
+		return err
+	}
+
+	var info struct {
+		ExitCode int
+	}
+	if err := r.call(ctx, http.MethodGet, execPath+"/json", nil, nil, &info); err != nil {
+		return fmt.Errorf("failed to get exit code of shell in container %q: %w", name, err)
+	}
+	return newExitError(info.ExitCode)
+}
+
+func (r *apiRuntime) RemoveContainers(ctx context.Context, names []string, options RemoveOptions) error {
+	query := url.Values{"v": {"1"}}
+	if options.Force {
+		query.Set("force", "1")
+	}
+	for _, name := range names {
+		if err := r.call(ctx, http.MethodDelete, containerPath(name), query, nil, nil); err != nil {
+			return fmt.Errorf("failed to remove container %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// getTerminal returns the file descriptor of stdin and whether it is a terminal
+func (r *apiRuntime) getTerminal() (int, bool) {
+	file, ok := r.stdin.(*os.File)
+	if !ok || !terminal.IsTerminal(int(file.Fd())) {
+		return 0, false
+	}
+	return int(file.Fd()), true
+}
+
+// stream copies stdin to given connection and output read from given reader to stdout/stderr, until
+// output ends. With a TTY, terminal is put in raw mode and its size is propagated via given resize func.
+func (r *apiRuntime) stream(conn net.Conn, reader io.Reader, tty bool, resize func(height, width int) error) error {
+	if fd, isTerminal := r.getTerminal(); tty && isTerminal {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal in raw mode: %w", err)
+		}
+		defer terminal.Restore(fd, state)
+
+		resizeTerminal := func() {
+			width, height, err := terminal.GetSize(fd)
+			if err != nil {
+				return
+			}
+			if err := resize(height, width); err != nil {
+				yey.Log("failed to resize terminal: %v", err)
+			}
+		}
+		resizeTerminal()
+		stop := watchTerminalSize(resizeTerminal)
+		defer stop()
+	}
+
+	go func() {
+		io.Copy(conn, r.stdin)
+		if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+			closer.CloseWrite()
+		}
+	}()
+
+	var err error
+	if tty {
+		_, err = io.Copy(r.stdout, reader)
+	} else {
+		err = demultiplex(reader, r.stdout, r.stderr)
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("failed to read container output: %w", err)
+	}
+	return nil
+}
+
+// demultiplex copies output of containers without a TTY to stdout and stderr, where output is made of
+// frames, each prefixed with an 8-byte header holding stream type and big-endian frame size
+func demultiplex(reader io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		writer := stdout
+		if header[0] == 2 {
+			writer = stderr
+		}
+		if _, err := io.CopyN(writer, reader, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// newExitError returns an ExitError for given non-zero exit code, or nil
+func newExitError(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return &ExitError{Code: code}
+}
@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeAPIRuntime returns an API runtime connected to a fake docker daemon, served by given
+// handler on a unix socket
+func newFakeAPIRuntime(t *testing.T, handler http.Handler) (*apiRuntime, *bytes.Buffer, *bytes.Buffer) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	runtime, err := newAPIRuntime("unix://" + socket)
+	require.NoError(t, err)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	runtime.stdin = strings.NewReader("")
+	runtime.stdout = stdout
+	runtime.stderr = stderr
+	return runtime, stdout, stderr
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	require.NoError(t, json.NewEncoder(w).Encode(value))
+}
+
+func writeNotFound(t *testing.T, w http.ResponseWriter, message string) {
+	writeJSON(t, w, http.StatusNotFound, map[string]string{"message": message})
+}
+
+// serveRawStream upgrades given request's connection and writes given frames to it, multiplexed
+// as for containers without a TTY
+func serveRawStream(t *testing.T, w http.ResponseWriter, stdout, stderr string) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	require.NoError(t, err)
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	for i, data := range []string{stdout, stderr} {
+		header := make([]byte, 8)
+		header[0] = byte(i + 1)
+		binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+		buf.Write(header)
+		buf.WriteString(data)
+	}
+	require.NoError(t, buf.Flush())
+}
+
+func TestNewAPIRuntime(t *testing.T) {
+	_, err := newAPIRuntime("")
+	assert.NoError(t, err)
+	_, err = newAPIRuntime("unix:///var/run/docker.sock")
+	assert.NoError(t, err)
+	_, err = newAPIRuntime("tcp://127.0.0.1:2375")
+	assert.NoError(t, err)
+	_, err = newAPIRuntime("ssh://user@host")
+	assert.EqualError(t, err, `unsupported DOCKER_HOST scheme "ssh"`)
+
+	os.Setenv("DOCKER_TLS_VERIFY", "1")
+	defer os.Unsetenv("DOCKER_TLS_VERIFY")
+	_, err = newAPIRuntime("tcp://127.0.0.1:2376")
+	assert.EqualError(t, err, "TLS connections to docker daemon are not supported")
+}
+
+func TestAPIGetContainerStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/running/json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]interface{}{"Id": "123", "State": map[string]string{"Status": "running"}})
+	})
+	mux.HandleFunc("/containers/missing/json", func(w http.ResponseWriter, r *http.Request) {
+		writeNotFound(t, w, "No such container: missing")
+	})
+	mux.HandleFunc("/containers/broken/json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusInternalServerError, map[string]string{"message": "boom"})
+	})
+	runtime, _, _ := newFakeAPIRuntime(t, mux)
+	ctx := context.Background()
+
+	status, err := runtime.GetContainerStatus(ctx, "running")
+	assert.NoError(t, err)
+	assert.Equal(t, "running", status)
+
+	status, err = runtime.GetContainerStatus(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "", status)
+
+	_, err = runtime.GetContainerStatus(ctx, "broken")
+	assert.EqualError(t, err, "failed to get container status: boom")
+}
+
+func TestAPIListContainers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("all"))
+		assert.JSONEq(t, `{"label":["yey.path"]}`, r.URL.Query().Get("filters"))
+		writeJSON(t, w, http.StatusOK, []map[string]interface{}{
+			{
+				"Names": []string{"/yey-project-prod-123"},
+				"Labels": map[string]string{
+					yey.LabelPath:    "/project/.yeyrc.yaml",
+					yey.LabelNames:   `["prod"]`,
+					yey.LabelHash:    "123",
+					yey.LabelVersion: "1.2.3",
+				},
+			},
+			{
+				"Names":  []string{"/invalid"},
+				"Labels": map[string]string{yey.LabelPath: "/project/.yeyrc.yaml"},
+			},
+		})
+	})
+	runtime, _, _ := newFakeAPIRuntime(t, mux)
+
+	containers, err := runtime.ListContainers(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, []Container{{
+		Name:    "yey-project-prod-123",
+		Path:    "/project/.yeyrc.yaml",
+		Names:   []string{"prod"},
+		Hash:    "123",
+		Version: "1.2.3",
+	}}, containers)
+}
+
+func TestAPIListLegacyContainers(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "", r.URL.Query().Get("all"))
+		assert.JSONEq(t, `{"name":["yey-"]}`, r.URL.Query().Get("filters"))
+		writeJSON(t, w, http.StatusOK, []map[string]interface{}{
+			{
+				"Names":  []string{"/yey-legacy-prod"},
+				"Labels": map[string]string{},
+			},
+			{
+				"Names":  []string{"/yey-project-prod-123"},
+				"Labels": map[string]string{yey.LabelPath: "/project/.yeyrc.yaml"},
+			},
+			{
+				"Names":  []string{"/not-yey-container"},
+				"Labels": map[string]string{},
+			},
+		})
+	})
+	runtime, _, _ := newFakeAPIRuntime(t, mux)
+
+	containers, err := runtime.ListLegacyContainers(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []Container{{Name: "yey-legacy-prod", Legacy: true}}, containers)
+}
+
+func TestAPIRunContainer(t *testing.T) {
+	var calls []string
+	created := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "create")
+		if !created {
+			writeNotFound(t, w, "No such image: alpine:3")
+			return
+		}
+		assert.Equal(t, "yey-project-prod-123", r.URL.Query().Get("name"))
+		assert.Equal(t, "linux/amd64", r.URL.Query().Get("platform"))
+		var config containerConfig
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&config))
+		assert.Equal(t, "alpine:3", config.Image)
+		assert.Equal(t, []string{"bash"}, config.Entrypoint)
+		assert.Equal(t, []string{"-l"}, config.Cmd)
+		assert.Contains(t, config.Env, "YEY_CONTEXT=prod")
+		assert.Contains(t, config.Env, "FOO=bar")
+		assert.Equal(t, map[string]string{yey.LabelPath: "/project/.yeyrc.yaml"}, config.Labels)
+		assert.Equal(t, "/src", config.WorkingDir)
+		assert.False(t, config.Tty)
+		assert.Equal(t, hostConfig{Binds: []string{"/home:/home"}, NetworkMode: "host", AutoRemove: true}, config.HostConfig)
+		writeJSON(t, w, http.StatusCreated, map[string]string{"Id": "abc"})
+	})
+	mux.HandleFunc("/images/create", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "pull")
+		assert.Equal(t, "alpine", r.URL.Query().Get("fromImage"))
+		assert.Equal(t, "3", r.URL.Query().Get("tag"))
+		created = true
+		w.Write([]byte(`{"status":"Pulling from library/alpine","id":"3"}` + "\n" + `{"status":"Downloading","progress":"[=>  ]","id":"123"}` + "\n"))
+	})
+	mux.HandleFunc("/containers/abc/attach", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "attach")
+		serveRawStream(t, w, "hello\n", "oops\n")
+	})
+	mux.HandleFunc("/containers/abc/wait", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "wait")
+		assert.Equal(t, "removed", r.URL.Query().Get("condition"))
+		writeJSON(t, w, http.StatusOK, map[string]int{"StatusCode": 3})
+	})
+	mux.HandleFunc("/containers/abc/start", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "start")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	runtime, stdout, stderr := newFakeAPIRuntime(t, mux)
+
+	remove := true
+	yeyCtx := yey.Context{
+		Name:       "prod",
+		Image:      "alpine:3",
+		Platform:   "linux/amd64",
+		EntryPoint: "bash",
+		Cmd:        []string{"-l"},
+		Env:        map[string]string{"FOO": "bar"},
+		Mounts:     map[string]string{"/home": "/home"},
+		Remove:     &remove,
+	}
+	options := RunOptions{WorkDir: "/src", Labels: map[string]string{yey.LabelPath: "/project/.yeyrc.yaml"}}
+	err := runtime.RunContainer(context.Background(), yeyCtx, "yey-project-prod-123", options)
+
+	assert.Equal(t, &ExitError{Code: 3}, err)
+	assert.Equal(t, []string{"create", "pull", "create", "attach", "wait", "start"}, calls)
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, "3: Pulling from library/alpine\noops\n", stderr.String())
+}
+
+func TestAPIExecContainer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/yey-project-prod-123/exec", func(w http.ResponseWriter, r *http.Request) {
+		var config execConfig
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&config))
+		assert.Equal(t, []string{"sh"}, config.Cmd)
+		assert.Equal(t, "/src", config.WorkingDir)
+		writeJSON(t, w, http.StatusCreated, map[string]string{"Id": "def"})
+	})
+	mux.HandleFunc("/exec/def/start", func(w http.ResponseWriter, r *http.Request) {
+		serveRawStream(t, w, "hello\n", "")
+	})
+	mux.HandleFunc("/exec/def/json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]int{"ExitCode": 0})
+	})
+	runtime, stdout, _ := newFakeAPIRuntime(t, mux)
+
+	err := runtime.ExecContainer(context.Background(), yey.Context{}, "yey-project-prod-123", RunOptions{WorkDir: "/src"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", stdout.String())
+}
+
+func TestAPIRemoveContainers(t *testing.T) {
+	var removed []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "1", r.URL.Query().Get("v"))
+		assert.Equal(t, "1", r.URL.Query().Get("force"))
+		removed = append(removed, strings.TrimPrefix(r.URL.Path, "/containers/"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	runtime, _, _ := newFakeAPIRuntime(t, mux)
+
+	err := runtime.RemoveContainers(context.Background(), []string{"a", "b"}, RemoveOptions{Force: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, removed)
+}
+
+func TestSplitImageTag(t *testing.T) {
+	cases := []struct {
+		Image string
+		Name  string
+		Tag   string
+	}{
+		{Image: "alpine", Name: "alpine", Tag: "latest"},
+		{Image: "alpine:3", Name: "alpine", Tag: "3"},
+		{Image: "localhost:5000/alpine", Name: "localhost:5000/alpine", Tag: "latest"},
+		{Image: "localhost:5000/alpine:3", Name: "localhost:5000/alpine", Tag: "3"},
+		{Image: "alpine@sha256:abc", Name: "alpine", Tag: "sha256:abc"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Image, func(t *testing.T) {
+			name, tag := splitImageTag(c.Image)
+			assert.Equal(t, c.Name, name)
+			assert.Equal(t, c.Tag, tag)
+		})
+	}
+}
+
+func TestDemultiplex(t *testing.T) {
+	var input bytes.Buffer
+	for _, frame := range []struct {
+		Stream byte
+		Data   string
+	}{{1, "out1"}, {2, "err1"}, {1, "out2"}} {
+		header := make([]byte, 8)
+		header[0] = frame.Stream
+		binary.BigEndian.PutUint32(header[4:], uint32(len(frame.Data)))
+		input.Write(header)
+		input.WriteString(frame.Data)
+	}
+	var stdout, stderr bytes.Buffer
+
+	err := demultiplex(&input, &stdout, &stderr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "out1out2", stdout.String())
+	assert.Equal(t, "err1", stderr.String())
+
+	err = demultiplex(strings.NewReader("\x01\x00\x00\x00\x00\x00\x00\x05abc"), io.Discard, io.Discard)
+	assert.Error(t, err)
+}
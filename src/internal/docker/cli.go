@@ -3,6 +3,8 @@ package docker
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,84 +14,74 @@ import (
 	"strings"
 
 	yey "github.com/silphid/yey/src/internal"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
-type RunOptions struct {
-	WorkDir string
-}
+// cliRuntime is a runtime shelling out to a docker-compatible CLI
+type cliRuntime struct {
+	// binary is the name of CLI executable
+	binary string
 
-func Run(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
-	// Determine whether we need to run or exec container
-	status, err := getContainerStatus(ctx, containerName)
-	if err != nil {
-		return err
-	}
+	// startFlags are the flags passed to `start` command to attach to container's input and output
+	startFlags []string
 
-	switch status {
-	case "":
-		yey.Log("running new container %q", containerName)
-		return runContainer(ctx, yeyCtx, containerName, options)
-	case "exited":
-		yey.Log("restarting stopped container %q", containerName)
-		return startContainer(ctx, containerName, options)
-	case "running":
-		yey.Log("executing new shell in running container %q", containerName)
-		return execContainer(ctx, yeyCtx, containerName, options)
-	default:
-		return fmt.Errorf("container %q in unexpected state %q", containerName, status)
-	}
-}
-
-type RemoveOptions struct {
-	Force bool
-}
-
-func Remove(ctx context.Context, containerName string, options RemoveOptions) error {
-	status, err := getContainerStatus(ctx, containerName)
-	if err != nil {
-		return err
-	}
+	// keepUserNamespace indicates that, when running rootless, host user must be mapped to same
+	// user in container, so that files written to mounted dirs are owned by host user
+	keepUserNamespace bool
 
-	if status == "" {
-		return nil
-	}
+	// createMountSources indicates that missing host dirs of mounts must be created before running
+	// container, as CLI does not create them the way docker does
+	createMountSources bool
 
-	return RemoveMany(ctx, []string{containerName}, options)
+	// defaultNetwork is the network to connect containers to when context specifies none, where an
+	// empty value leaves it to CLI's own default
+	defaultNetwork string
 }
 
-func RemoveMany(ctx context.Context, containers []string, options RemoveOptions) error {
-	if len(containers) == 0 {
-		return nil
-	}
+// cliRuntimes are the supported CLI runtimes, by name
+var cliRuntimes = map[string]cliRuntime{
+	DockerRuntime: {
+		binary:         "docker",
+		startFlags:     []string{"-i"},
+		defaultNetwork: "host",
+	},
+	PodmanRuntime: {
+		binary:             "podman",
+		startFlags:         []string{"-a", "-i"},
+		keepUserNamespace:  true,
+		createMountSources: true,
+		defaultNetwork:     "host",
+	},
+
+	// Host network of rootless nerdctl is that of its own namespace rather than that of host, so
+	// it is left to its default bridge network
+	NerdctlRuntime: {
+		binary:             "nerdctl",
+		startFlags:         []string{"--attach"},
+		createMountSources: true,
+	},
+}
 
+func (r cliRuntime) RemoveContainers(ctx context.Context, containers []string, options RemoveOptions) error {
 	args := []string{"rm", "-v"}
 	if options.Force {
 		args = append(args, "-f")
 	}
 	args = append(args, containers...)
 
-	return run(ctx, args...)
+	return r.run(ctx, args...)
 }
 
-func Pull(ctx context.Context, image, platform string) error {
+func (r cliRuntime) Pull(ctx context.Context, image, platform string) error {
 	args := []string{"pull"}
 	if platform != "" {
 		args = append(args, "--platform", platform)
 	}
 	args = append(args, image)
-	return run(ctx, args...)
+	return r.run(ctx, args...)
 }
 
-func Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs map[string]string, context string) error {
-	exists, err := imageExists(ctx, imageTag)
-	if err != nil {
-		return fmt.Errorf("failed to look up image tag %q: %w", imageTag, err)
-	}
-	if exists {
-		yey.Log("found prebuilt image: %q: skipping build step", imageTag)
-		return nil
-	}
-
+func (r cliRuntime) Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs map[string]string, context string) error {
 	args := []string{"build", "-f", dockerPath, "-t", imageTag}
 	for key, value := range buildArgs {
 		args = append(args, "--build-arg", fmt.Sprintf("%s=%q", key, value))
@@ -102,39 +94,99 @@ func Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs
 	}
 	args = append(args, context)
 
-	return run(ctx, args...)
+	return r.run(ctx, args...)
 }
 
 var newlines = regexp.MustCompile(`\r?\n`)
 
-func ListContainers(ctx context.Context, all bool) ([]string, error) {
-	// Compute args
-	args := []string{"ps", "--filter", "name=yey-*", "--format", "{{.Names}}"}
+// containerFormat is the format passed to `inspect` command to output name and labels of containers,
+// as one JSON object per line
+const containerFormat = `{"name":{{json .Name}},"labels":{{json .Config.Labels}}}`
+
+func (r cliRuntime) ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	return r.listContainers(ctx, all, "label="+yey.LabelPath, false)
+}
+
+func (r cliRuntime) ListLegacyContainers(ctx context.Context, all bool) ([]Container, error) {
+	return r.listContainers(ctx, all, "name="+containerNamePrefix, true)
+}
+
+// listContainers returns the containers matching given filter, which are either labeled containers or,
+// when legacy is true, unlabeled containers created by older versions of yey
+func (r cliRuntime) listContainers(ctx context.Context, all bool, filter string, legacy bool) ([]Container, error) {
+	// List IDs of matching containers
+	args := []string{"ps", "--quiet", "--filter", filter}
 	if all {
 		args = append(args, "--all")
 	}
-
-	cmd := exec.Command("docker", args...)
-
-	// Parse output
-	outputBuf, err := cmd.Output()
+	output, err := r.output(ctx, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute command: docker %s", strings.Join(args, " "))
+		return nil, err
 	}
-	output := string(bytes.TrimSpace(outputBuf))
 	if output == "" {
-		return []string{}, nil
+		return []Container{}, nil
 	}
-	containers := newlines.Split(string(output), -1)
 
-	// Sort
-	sort.Strings(containers)
+	// Inspect their names and labels
+	args = append([]string{"inspect", "--format", containerFormat}, newlines.Split(output, -1)...)
+	output, err = r.output(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	containers := []Container{}
+	for _, line := range newlines.Split(output, -1) {
+		container, ok, err := decodeContainer(line, legacy)
+		if err != nil {
+			yey.Warn("ignoring container with invalid labels: %v", err)
+			continue
+		}
+		if ok {
+			containers = append(containers, container)
+		}
+	}
 	return containers, nil
 }
 
-func imageExists(ctx context.Context, tag string) (bool, error) {
-	output, err := exec.CommandContext(ctx, "docker", "image", "inspect", tag).Output()
-	if string(bytes.TrimSpace(output)) == "[]" {
+// decodeContainer parses a line output by `inspect` command for containerFormat, and returns whether it
+// is a labeled container or, when legacy is true, an unlabeled container created by an older version of
+// yey (name filter also matches containers merely containing prefix in their name)
+func decodeContainer(line string, legacy bool) (Container, bool, error) {
+	var item struct {
+		Name   string
+		Labels map[string]string
+	}
+	if err := json.Unmarshal([]byte(line), &item); err != nil {
+		return Container{}, false, fmt.Errorf("unexpected container format %q: %w", line, err)
+	}
+	name := strings.TrimPrefix(item.Name, "/")
+	if legacy {
+		return Container{Name: name, Legacy: true}, isLegacyContainer(name, item.Labels), nil
+	}
+	container, err := newContainer(name, item.Labels)
+	return container, err == nil, err
+}
+
+// output runs CLI with given args and returns its trimmed output
+func (r cliRuntime) output(ctx context.Context, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, r.binary, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute command: %s %s: %w", r.binary, strings.Join(args, " "), err)
+	}
+	return string(bytes.TrimSpace(output)), nil
+}
+
+func (r cliRuntime) GetContainerLabel(ctx context.Context, containerName, label string) (string, error) {
+	format := fmt.Sprintf("{{index .Config.Labels %q}}", label)
+	output, err := exec.CommandContext(ctx, r.binary, "inspect", containerName, "--format", format).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %q: %w", containerName, err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+func (r cliRuntime) ImageExists(ctx context.Context, tag string) (bool, error) {
+	output, err := exec.CommandContext(ctx, r.binary, "image", "inspect", tag).CombinedOutput()
+	if string(bytes.TrimSpace(output)) == "[]" || isNotFoundOutput(output) {
 		return false, nil
 	}
 	if err != nil {
@@ -143,12 +195,19 @@ func imageExists(ctx context.Context, tag string) (bool, error) {
 	return true, nil
 }
 
-func getContainerStatus(ctx context.Context, name string) (string, error) {
-	cmd := exec.CommandContext(ctx, "docker", "inspect", name, "--format", "{{.State.Status}}")
+// isNotFoundOutput returns whether given CLI output reports a missing object, in the wording of any
+// supported CLI
+func isNotFoundOutput(output []byte) bool {
+	text := strings.ToLower(string(output))
+	return strings.Contains(text, "no such") || strings.Contains(text, "not found")
+}
+
+func (r cliRuntime) GetContainerStatus(ctx context.Context, name string) (string, error) {
+	cmd := exec.CommandContext(ctx, r.binary, "inspect", name, "--format", "{{.State.Status}}")
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		if strings.Contains(string(output), "No such object") {
+		if isNotFoundOutput(output) {
 			return "", nil
 		}
 		return "", fmt.Errorf("failed to get container status:  %s: %w", output, err)
@@ -157,24 +216,47 @@ func getContainerStatus(ctx context.Context, name string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func runContainer(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
+func (r cliRuntime) RunContainer(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
 
-	args := []string{
-		"run",
-		"-it",
+	args := append([]string{"run"}, getInteractiveFlags()...)
+	args = append(args,
 		"--name", containerName,
-		"--env", "YEY_WORK_DIR=" + cwd,
-		"--env", "YEY_CONTEXT=" + yeyCtx.Name,
-	}
+		"--env", "YEY_WORK_DIR="+cwd,
+		"--env", "YEY_CONTEXT="+yeyCtx.Name,
+	)
 
 	if yeyCtx.Platform != "" {
 		args = append(args, "--platform", yeyCtx.Platform)
 	}
 
+	// User
+	if yeyCtx.User != "" {
+		args = append(args, "--user", yeyCtx.User)
+	}
+	if r.keepUserNamespace {
+		rootless, err := r.isRootless(ctx)
+		if err != nil {
+			return err
+		}
+		if rootless {
+			args = append(args, "--userns", "keep-id")
+		}
+	}
+
+	// Labels
+	labelKeys := make([]string, 0, len(options.Labels))
+	for key := range options.Labels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", key, options.Labels[key]))
+	}
+
 	// Context env vars
 	for name, value := range yeyCtx.Env {
 		args = append(args, "--env", fmt.Sprintf("%s=%s", name, value))
@@ -182,6 +264,11 @@ func runContainer(ctx context.Context, yeyCtx yey.Context, containerName string,
 
 	// Mount binds
 	for key, value := range yeyCtx.Mounts {
+		if r.createMountSources && !yey.IsDryRun {
+			if err := createMountSource(key); err != nil {
+				return err
+			}
+		}
 		args = append(
 			args,
 			"--volume",
@@ -195,11 +282,9 @@ func runContainer(ctx context.Context, yeyCtx yey.Context, containerName string,
 	}
 
 	// Network mode
-	network := yeyCtx.Network
-	if network == "" {
-		network = "host"
+	if network := getNetwork(yeyCtx, r.defaultNetwork); network != "" {
+		args = append(args, "--network", network)
 	}
-	args = append(args, "--network", network)
 
 	// Work directory
 	if options.WorkDir != "" {
@@ -219,37 +304,100 @@ func runContainer(ctx context.Context, yeyCtx yey.Context, containerName string,
 	args = append(args, yeyCtx.Image)
 	args = append(args, yeyCtx.Cmd...)
 
-	return run(ctx, args...)
+	return r.attach(ctx, args...)
 }
 
-func startContainer(ctx context.Context, containerName string, options RunOptions) error {
-	return run(ctx, "start", "-i", containerName)
+func (r cliRuntime) StartContainer(ctx context.Context, containerName string, options RunOptions) error {
+	if options.Detach {
+		return r.run(ctx, "start", containerName)
+	}
+	args := append([]string{"start"}, r.startFlags...)
+	return r.attach(ctx, append(args, containerName)...)
 }
 
-func execContainer(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
-	args := []string{"exec", "-ti"}
+func (r cliRuntime) ExecContainer(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
+	args := append([]string{"exec"}, getInteractiveFlags()...)
 	if options.WorkDir != "" {
 		args = append(args, "--workdir", options.WorkDir)
 	}
 	args = append(args, containerName)
+	args = append(args, getExecCmd(yeyCtx)...)
 
-	// Entrypoint/command
+	return r.attach(ctx, args...)
+}
+
+// isRootless returns whether CLI runs containers without root privileges
+func (r cliRuntime) isRootless(ctx context.Context) (bool, error) {
+	if yey.IsDryRun {
+		return false, nil
+	}
+	output, err := r.output(ctx, "info", "--format", "{{.Host.Security.Rootless}}")
+	if err != nil {
+		return false, err
+	}
+	return output == "true", nil
+}
+
+// createMountSource creates given host dir to mount, if it does not exist
+func createMountSource(path string) error {
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	yey.Log("creating missing mount dir %q", path)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return fmt.Errorf("failed to create mount dir %q: %w", path, err)
+	}
+	return nil
+}
+
+// getNetwork returns the network to connect container to, defaulting to given runtime's default network
+func getNetwork(yeyCtx yey.Context, defaultNetwork string) string {
+	if yeyCtx.Network == "" {
+		return defaultNetwork
+	}
+	return yeyCtx.Network
+}
+
+// getExecCmd returns the command to execute in running container
+func getExecCmd(yeyCtx yey.Context) []string {
 	if yeyCtx.EntryPoint == "" && len(yeyCtx.Cmd) == 0 {
 		// Default to sh because docker exec requires some command
-		args = append(args, "sh")
-	} else {
-		if yeyCtx.EntryPoint != "" {
-			args = append(args, yeyCtx.EntryPoint)
-		}
-		args = append(args, yeyCtx.Cmd...)
+		return []string{"sh"}
+	}
+	var cmd []string
+	if yeyCtx.EntryPoint != "" {
+		cmd = append(cmd, yeyCtx.EntryPoint)
+	}
+	return append(cmd, yeyCtx.Cmd...)
+}
+
+// isTerminal returns whether stdin is a terminal, in which case a TTY is allocated to container
+var isTerminal = func() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// getInteractiveFlags returns the flags to keep stdin open, also allocating a TTY if stdin is a terminal
+func getInteractiveFlags() []string {
+	if isTerminal() {
+		return []string{"-it"}
 	}
+	return []string{"-i"}
+}
 
-	return run(ctx, args...)
+// attach runs CLI with given args, attached to a container process, returning an ExitError when that
+// process exits with a non-zero code
+func (r cliRuntime) attach(ctx context.Context, args ...string) error {
+	err := r.run(ctx, args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
 }
 
-func run(ctx context.Context, args ...string) error {
+func (r cliRuntime) run(ctx context.Context, args ...string) error {
 	if yey.IsDryRun || yey.IsVerbose {
-		cmd := fmt.Sprintf("docker %s", strings.Join(quoteArgsWithSpecialChars(args), " "))
+		cmd := fmt.Sprintf("%s %s", r.binary, strings.Join(quoteArgsWithSpecialChars(args), " "))
 		if yey.IsDryRun {
 			fmt.Println(cmd)
 			return nil
@@ -257,7 +405,7 @@ func run(ctx context.Context, args ...string) error {
 		yey.Log(cmd)
 	}
 
-	return attachStdPipes(exec.CommandContext(ctx, "docker", args...)).Run()
+	return attachStdPipes(exec.CommandContext(ctx, r.binary, args...)).Run()
 }
 
 var specialCharsRegex = regexp.MustCompile(`\s`)
@@ -0,0 +1,313 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeCLIRuntime returns a copy of runtime with given name, where CLI binary is replaced with a
+// fake one executing given shell script, after recording its args (space-separated, one call per line)
+// to the file whose path is returned
+func newFakeCLIRuntime(t *testing.T, name, script string) (cliRuntime, string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args.log")
+	binary := filepath.Join(dir, name)
+	content := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + log + "\n" + script + "\n"
+	require.NoError(t, os.WriteFile(binary, []byte(content), 0755))
+
+	runtime := cliRuntimes[name]
+	runtime.binary = binary
+	return runtime, log
+}
+
+func readArgs(t *testing.T, log string) []string {
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestDecodeContainer(t *testing.T) {
+	container, ok, err := decodeContainer(`{"name":"/yey-project-prod-go-123","labels":{"yey.path":"/project/.yeyrc.yaml","yey.names":"[\"prod\",\"go\"]","yey.hash":"123","yey.version":"1.2.3","yey.created":"2021-06-01T17:00:00Z"}}`, false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Container{
+		Name:    "yey-project-prod-go-123",
+		Path:    "/project/.yeyrc.yaml",
+		Names:   []string{"prod", "go"},
+		Hash:    "123",
+		Version: "1.2.3",
+		Created: time.Date(2021, 6, 1, 17, 0, 0, 0, time.UTC),
+	}, container)
+	assert.Equal(t, "prod go", container.ContextName())
+}
+
+func TestDecodeLegacyContainer(t *testing.T) {
+	container, ok, err := decodeContainer(`{"name":"/yey-project-prod-go-123","labels":{}}`, true)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Container{Name: "yey-project-prod-go-123", Legacy: true}, container)
+
+	_, ok, err = decodeContainer(`{"name":"/my-yey-container","labels":{}}`, true)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = decodeContainer(`{"name":"/yey-project-prod-go-456","labels":{"yey.path":"/project/.yeyrc.yaml"}}`, true)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDecodeContainerErrors(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Line  string
+		Error string
+	}{
+		{
+			Name:  "invalid format",
+			Line:  "yey-project-prod",
+			Error: `unexpected container format "yey-project-prod"`,
+		},
+		{
+			Name:  "invalid names",
+			Line:  `{"name":"yey-project-prod","labels":{"yey.path":"/project/.yeyrc.yaml","yey.names":"prod"}}`,
+			Error: `container "yey-project-prod": invalid yey.names label "prod"`,
+		},
+		{
+			Name:  "invalid created",
+			Line:  `{"name":"yey-project-prod","labels":{"yey.path":"/project/.yeyrc.yaml","yey.names":"[\"prod\"]","yey.created":"yesterday"}}`,
+			Error: `container "yey-project-prod": invalid yey.created label "yesterday"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			_, _, err := decodeContainer(c.Line, false)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.Error)
+		})
+	}
+}
+
+func TestCLIListContainers(t *testing.T) {
+	runtime, log := newFakeCLIRuntime(t, PodmanRuntime, `
+case "$1" in
+	ps) printf 'abc\ndef\n' ;;
+	inspect) printf '%s\n' '{"name":"yey-project-prod-123","labels":{"yey.path":"/project/.yeyrc.yaml","yey.names":"[\"prod\"]"}}' '{"name":"yey-project-dev-456","labels":{"yey.path":"/project/.yeyrc.yaml","yey.names":"[\"dev\"]"}}' ;;
+esac`)
+
+	containers, err := runtime.ListContainers(context.Background(), true)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Container{
+		{Name: "yey-project-prod-123", Path: "/project/.yeyrc.yaml", Names: []string{"prod"}},
+		{Name: "yey-project-dev-456", Path: "/project/.yeyrc.yaml", Names: []string{"dev"}},
+	}, containers)
+	assert.Equal(t, []string{
+		"ps --quiet --filter label=yey.path --all",
+		"inspect --format " + containerFormat + " abc def",
+	}, readArgs(t, log))
+}
+
+func TestCLIListLegacyContainers(t *testing.T) {
+	runtime, log := newFakeCLIRuntime(t, DockerRuntime, `
+case "$1" in
+	ps) printf 'abc\ndef\n' ;;
+	inspect) printf '%s\n' '{"name":"/yey-project-prod-123","labels":{}}' '{"name":"/my-yey-container","labels":{}}' ;;
+esac`)
+
+	containers, err := runtime.ListLegacyContainers(context.Background(), true)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Container{{Name: "yey-project-prod-123", Legacy: true}}, containers)
+	assert.Equal(t, []string{
+		"ps --quiet --filter name=yey- --all",
+		"inspect --format " + containerFormat + " abc def",
+	}, readArgs(t, log))
+}
+
+func TestCLIListContainersWithoutContainers(t *testing.T) {
+	runtime, log := newFakeCLIRuntime(t, NerdctlRuntime, "")
+
+	containers, err := runtime.ListContainers(context.Background(), false)
+
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+	assert.Equal(t, []string{"ps --quiet --filter label=yey.path"}, readArgs(t, log))
+}
+
+func TestCLIGetContainerStatus(t *testing.T) {
+	runtime, _ := newFakeCLIRuntime(t, PodmanRuntime, `
+case "$2" in
+	running) echo running ;;
+	*) echo "Error: no such container $2" >&2; exit 125 ;;
+esac`)
+	ctx := context.Background()
+
+	status, err := runtime.GetContainerStatus(ctx, "running")
+	assert.NoError(t, err)
+	assert.Equal(t, "running", status)
+
+	status, err = runtime.GetContainerStatus(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "", status)
+}
+
+func TestCLIRunContainer(t *testing.T) {
+	cases := []struct {
+		Name            string
+		Runtime         string
+		Rootless        bool
+		Network         string
+		ExpectedArgs    []string
+		ExpectedNetwork string
+		CreatesMounts   bool
+	}{
+		{
+			Name:            "docker",
+			Runtime:         DockerRuntime,
+			ExpectedArgs:    []string{"--user 1000:1000 --label yey.path=/project/.yeyrc.yaml"},
+			ExpectedNetwork: "--network host ",
+		},
+		{
+			Name:            "docker with network",
+			Runtime:         DockerRuntime,
+			Network:         "bridge",
+			ExpectedArgs:    []string{"--user 1000:1000 --label yey.path=/project/.yeyrc.yaml"},
+			ExpectedNetwork: "--network bridge ",
+		},
+		{
+			Name:            "rootless podman",
+			Runtime:         PodmanRuntime,
+			Rootless:        true,
+			ExpectedArgs:    []string{"info --format {{.Host.Security.Rootless}}", "--user 1000:1000 --userns keep-id --label yey.path=/project/.yeyrc.yaml"},
+			ExpectedNetwork: "--network host ",
+			CreatesMounts:   true,
+		},
+		{
+			Name:            "rootful podman",
+			Runtime:         PodmanRuntime,
+			ExpectedArgs:    []string{"info --format {{.Host.Security.Rootless}}", "--user 1000:1000 --label yey.path=/project/.yeyrc.yaml"},
+			ExpectedNetwork: "--network host ",
+			CreatesMounts:   true,
+		},
+		{
+			Name:          "nerdctl",
+			Runtime:       NerdctlRuntime,
+			ExpectedArgs:  []string{"--user 1000:1000 --label yey.path=/project/.yeyrc.yaml"},
+			CreatesMounts: true,
+		},
+		{
+			Name:            "nerdctl with network",
+			Runtime:         NerdctlRuntime,
+			Network:         "host",
+			ExpectedArgs:    []string{"--user 1000:1000 --label yey.path=/project/.yeyrc.yaml"},
+			ExpectedNetwork: "--network host ",
+			CreatesMounts:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			runtime, log := newFakeCLIRuntime(t, c.Runtime, fmt.Sprintf(`if [ "$1" = info ]; then echo %t; fi`, c.Rootless))
+			mountSource := filepath.Join(t.TempDir(), "missing")
+			yeyCtx := yey.Context{
+				Name:     "prod",
+				Image:    "alpine",
+				Platform: "linux/arm64",
+				User:     "1000:1000",
+				Env:      map[string]string{"FOO": "bar"},
+				Mounts:   map[string]string{mountSource: "/data"},
+				Network:  c.Network,
+			}
+			options := RunOptions{WorkDir: "/data", Labels: map[string]string{yey.LabelPath: "/project/.yeyrc.yaml"}}
+
+			err := runtime.RunContainer(context.Background(), yeyCtx, "yey-project-prod-123", options)
+
+			require.NoError(t, err)
+			args := readArgs(t, log)
+			runArgs := args[len(args)-1]
+			assert.Contains(t, runArgs, "run -i --name yey-project-prod-123 ")
+			assert.Contains(t, runArgs, "--platform linux/arm64 "+c.ExpectedArgs[len(c.ExpectedArgs)-1]+" ")
+			assert.Contains(t, runArgs, "--env FOO=bar --volume "+mountSource+":/data "+c.ExpectedNetwork+"--workdir /data alpine")
+			assert.Equal(t, c.ExpectedArgs[:len(c.ExpectedArgs)-1], args[:len(args)-1])
+			_, err = os.Stat(mountSource)
+			assert.Equal(t, c.CreatesMounts, err == nil)
+		})
+	}
+}
+
+func TestCLIStartContainer(t *testing.T) {
+	cases := map[string]string{
+		DockerRuntime:  "start -i yey-project-prod-123",
+		PodmanRuntime:  "start -a -i yey-project-prod-123",
+		NerdctlRuntime: "start --attach yey-project-prod-123",
+	}
+
+	for name, expected := range cases {
+		t.Run(name, func(t *testing.T) {
+			runtime, log := newFakeCLIRuntime(t, name, "")
+
+			err := runtime.StartContainer(context.Background(), "yey-project-prod-123", RunOptions{})
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{expected}, readArgs(t, log))
+		})
+	}
+}
+
+func TestCLIInteractiveFlags(t *testing.T) {
+	defer func(original func() bool) { isTerminal = original }(isTerminal)
+	runtime, log := newFakeCLIRuntime(t, DockerRuntime, "")
+	ctx := context.Background()
+	yeyCtx := yey.Context{Image: "alpine", Cmd: []string{"make", "test"}}
+
+	isTerminal = func() bool { return true }
+	require.NoError(t, runtime.RunContainer(ctx, yeyCtx, "yey-project-prod-123", RunOptions{}))
+	require.NoError(t, runtime.ExecContainer(ctx, yeyCtx, "yey-project-prod-123", RunOptions{}))
+	isTerminal = func() bool { return false }
+	require.NoError(t, runtime.ExecContainer(ctx, yeyCtx, "yey-project-prod-123", RunOptions{}))
+
+	args := readArgs(t, log)
+	assert.Contains(t, args[0], "run -it --name yey-project-prod-123 ")
+	assert.Equal(t, "exec -it yey-project-prod-123 make test", args[1])
+	assert.Equal(t, "exec -i yey-project-prod-123 make test", args[2])
+}
+
+func TestCLIPropagatesExitCode(t *testing.T) {
+	runtime, log := newFakeCLIRuntime(t, DockerRuntime, `if [ "$2" = -i ]; then exit 3; fi`)
+	ctx := context.Background()
+
+	err := runtime.StartContainer(ctx, "yey-project-prod-123", RunOptions{})
+	assert.Equal(t, &ExitError{Code: 3}, err)
+
+	err = runtime.StartContainer(ctx, "yey-project-prod-123", RunOptions{Detach: true})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"start -i yey-project-prod-123", "start yey-project-prod-123"}, readArgs(t, log))
+}
+
+func TestUseRuntime(t *testing.T) {
+	defer func() { runtimeName = "" }()
+
+	assert.NoError(t, UseRuntime(""))
+	assert.Equal(t, DockerRuntime, runtimeName)
+
+	assert.NoError(t, UseRuntime(PodmanRuntime))
+	assert.Equal(t, PodmanRuntime, runtimeName)
+
+	assert.EqualError(t, UseRuntime("lxc"), `unsupported runtime "lxc" (expecting one of: docker, podman, nerdctl)`)
+
+	os.Setenv(RuntimeEnvVar, NerdctlRuntime)
+	defer os.Unsetenv(RuntimeEnvVar)
+	assert.NoError(t, UseRuntime(PodmanRuntime))
+	assert.Equal(t, NerdctlRuntime, runtimeName)
+}
@@ -0,0 +1,428 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	yey "github.com/silphid/yey/src/internal"
+)
+
+// Runtime represents a backend able to manage containers
+type Runtime interface {
+	// GetContainerStatus returns the status of container with given name (ie: `running`, `exited`),
+	// or an empty string if no such container exists
+	GetContainerStatus(ctx context.Context, name string) (string, error)
+
+	// ListContainers returns the containers created by yey, including stopped ones when all is true
+	ListContainers(ctx context.Context, all bool) ([]Container, error)
+
+	// ListLegacyContainers returns the unlabeled containers created by older versions of yey, including
+	// stopped ones when all is true
+	ListLegacyContainers(ctx context.Context, all bool) ([]Container, error)
+
+	// GetContainerLabel returns the value of given label on container with given name
+	GetContainerLabel(ctx context.Context, name, label string) (string, error)
+
+	// ImageExists returns whether image with given tag exists locally
+	ImageExists(ctx context.Context, tag string) (bool, error)
+
+	// Pull pulls given image from registry
+	Pull(ctx context.Context, image, platform string) error
+
+	// Build builds given Dockerfile into image with given tag
+	Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs map[string]string, context string) error
+
+	// RunContainer creates and starts a new container, attaching to it until it exits
+	RunContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error
+
+	// StartContainer starts a stopped container, attaching to it until it exits (unless detached)
+	StartContainer(ctx context.Context, name string, options RunOptions) error
+
+	// ExecContainer executes a new shell in a running container, attaching to it until it exits
+	ExecContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error
+
+	// RemoveContainers removes given containers, along with their anonymous volumes
+	RemoveContainers(ctx context.Context, names []string, options RemoveOptions) error
+}
+
+// ExitError is returned when the container process attached to exits with a non-zero code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Names of supported runtimes
+const (
+	DockerRuntime  = "docker"
+	PodmanRuntime  = "podman"
+	NerdctlRuntime = "nerdctl"
+)
+
+// RuntimeEnvVar is the env var selecting runtime, which takes precedence over context's runtime
+const RuntimeEnvVar = "YEY_RUNTIME"
+
+var (
+	runtimeName  string
+	runtimes     = make(map[string]Runtime)
+	runtimesLock sync.Mutex
+)
+
+// UseRuntime selects runtime with given name (defaults to docker) for all subsequent operations,
+// unless overridden by YEY_RUNTIME env var
+func UseRuntime(name string) error {
+	name, err := getRuntimeName(name)
+	if err != nil {
+		return err
+	}
+	runtimeName = name
+	return nil
+}
+
+// getRuntimeName returns the name of runtime to use, given the name requested by context
+func getRuntimeName(name string) (string, error) {
+	if value := os.Getenv(RuntimeEnvVar); value != "" {
+		name = value
+	}
+	if name == "" {
+		return DockerRuntime, nil
+	}
+	if _, ok := cliRuntimes[name]; !ok {
+		return "", fmt.Errorf("unsupported runtime %q (expecting one of: %s, %s, %s)", name, DockerRuntime, PodmanRuntime, NerdctlRuntime)
+	}
+	return name, nil
+}
+
+// getRuntime returns the selected runtime, where docker runtime talks to daemon through its API
+// whenever possible, falling back to docker CLI otherwise
+func getRuntime(ctx context.Context) (Runtime, error) {
+	name, err := getRuntimeName(runtimeName)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimesLock.Lock()
+	defer runtimesLock.Unlock()
+	if runtime, ok := runtimes[name]; ok {
+		return runtime, nil
+	}
+	runtime := Runtime(cliRuntimes[name])
+	if name == DockerRuntime {
+		runtime = newDockerRuntime(ctx)
+	}
+	runtimes[name] = runtime
+	return runtime, nil
+}
+
+func newDockerRuntime(ctx context.Context) Runtime {
+	cli := cliRuntimes[DockerRuntime]
+
+	// Dry-run mode displays equivalent docker commands
+	if yey.IsDryRun {
+		return cli
+	}
+
+	api, err := newAPIRuntime(os.Getenv("DOCKER_HOST"))
+	if err != nil {
+		yey.Log("using docker CLI: %v", err)
+		return cli
+	}
+	if err := api.Ping(ctx); err != nil {
+		yey.Log("using docker CLI: %v", err)
+		return cli
+	}
+	return api
+}
+
+type RunOptions struct {
+	WorkDir string
+
+	// Labels are stamped on container when it gets created
+	Labels map[string]string
+
+	// OneOff indicates that context's Cmd is a one-off command, which must be executed in existing
+	// container or otherwise in a fresh container removed upon exit
+	OneOff bool
+
+	// Detach indicates that container must be started in background, without attaching to it
+	Detach bool
+}
+
+func Run(ctx context.Context, yeyCtx yey.Context, containerName string, options RunOptions) error {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Determine whether we need to run or exec container
+	status, err := runtime.GetContainerStatus(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case "":
+		if options.OneOff {
+			// Distinct name prevents conflicting with regular container, should one be created meanwhile
+			containerName, err = getOneOffContainerName(containerName)
+			if err != nil {
+				return err
+			}
+			yey.Log("running command in new container %q", containerName)
+			remove := true
+			yeyCtx.Remove = &remove
+		} else {
+			yey.Log("running new container %q", containerName)
+		}
+		return runtime.RunContainer(ctx, yeyCtx, containerName, options)
+	case "exited", "created":
+		if !options.OneOff {
+			yey.Log("restarting stopped container %q", containerName)
+			return runtime.StartContainer(ctx, containerName, options)
+		}
+
+		// Start container in background, in order to execute command in it
+		yey.Log("starting stopped container %q in background", containerName)
+		detachOptions := options
+		detachOptions.Detach = true
+		if err := runtime.StartContainer(ctx, containerName, detachOptions); err != nil {
+			return err
+		}
+		yey.Log("executing command in container %q", containerName)
+		return runtime.ExecContainer(ctx, yeyCtx, containerName, options)
+	case "running":
+		if options.OneOff {
+			yey.Log("executing command in running container %q", containerName)
+		} else {
+			yey.Log("executing new shell in running container %q", containerName)
+		}
+		return runtime.ExecContainer(ctx, yeyCtx, containerName, options)
+	default:
+		return fmt.Errorf("container %q in unexpected state %q", containerName, status)
+	}
+}
+
+// getOneOffContainerName returns a unique name for a one-off container, derived from given regular
+// container name
+func getOneOffContainerName(containerName string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate one-off container name: %w", err)
+	}
+	return fmt.Sprintf("%s-run-%x", containerName, suffix), nil
+}
+
+type RemoveOptions struct {
+	Force bool
+}
+
+func Remove(ctx context.Context, containerName string, options RemoveOptions) error {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := runtime.GetContainerStatus(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	if status == "" {
+		return nil
+	}
+
+	return runtime.RemoveContainers(ctx, []string{containerName}, options)
+}
+
+func RemoveMany(ctx context.Context, containers []string, options RemoveOptions) error {
+	if len(containers) == 0 {
+		return nil
+	}
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	return runtime.RemoveContainers(ctx, containers, options)
+}
+
+func Pull(ctx context.Context, image, platform string) error {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	return runtime.Pull(ctx, image, platform)
+}
+
+func Build(ctx context.Context, dockerPath, imageTag, platform string, buildArgs map[string]string, context string) error {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := runtime.ImageExists(ctx, imageTag)
+	if err != nil {
+		return fmt.Errorf("failed to look up image tag %q: %w", imageTag, err)
+	}
+	if exists {
+		yey.Log("found prebuilt image: %q: skipping build step", imageTag)
+		return nil
+	}
+
+	return runtime.Build(ctx, dockerPath, imageTag, platform, buildArgs, context)
+}
+
+// ListContainers returns the containers created by yey, sorted by RC file path and context names,
+// including stopped ones when all is true
+func ListContainers(ctx context.Context, all bool) ([]Container, error) {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := runtime.ListContainers(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	sortContainers(containers)
+	return containers, nil
+}
+
+// ListContainersWithLegacy returns the containers created by yey, like ListContainers, along with the
+// unlabeled containers created by older versions of yey. Those are associated with given RC file path,
+// or that of any labeled container, whose container name prefix they match (if any).
+func ListContainersWithLegacy(ctx context.Context, all bool, path string) ([]Container, error) {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := runtime.ListContainers(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	legacyContainers, err := runtime.ListLegacyContainers(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	if path != "" {
+		paths = append(paths, path)
+	}
+	for _, container := range containers {
+		if !stringIsInStrings(container.Path, paths) {
+			paths = append(paths, container.Path)
+		}
+	}
+	containers = append(containers, mapLegacyContainers(legacyContainers, paths)...)
+	sortContainers(containers)
+	return containers, nil
+}
+
+// sortContainers sorts given containers by RC file path and context names
+func sortContainers(containers []Container) {
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].Path != containers[j].Path {
+			return containers[i].Path < containers[j].Path
+		}
+		if containers[i].ContextName() != containers[j].ContextName() {
+			return containers[i].ContextName() < containers[j].ContextName()
+		}
+		return containers[i].Name < containers[j].Name
+	})
+}
+
+// mapLegacyContainers associates each of given legacy containers with the first of given RC file paths
+// whose container name prefix it matches, leaving it without path otherwise
+func mapLegacyContainers(containers []Container, paths []string) []Container {
+	mapped := make([]Container, 0, len(containers))
+	for _, container := range containers {
+		for _, path := range paths {
+			if strings.HasPrefix(container.Name, yey.ContainerPathPrefix(path)+"-") {
+				container.Path = path
+				break
+			}
+		}
+		mapped = append(mapped, container)
+	}
+	return mapped
+}
+
+func stringIsInStrings(value string, values []string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetContainerLabel returns the value of given label on container with given name, or an empty string
+// if container has no such label
+func GetContainerLabel(ctx context.Context, containerName, label string) (string, error) {
+	runtime, err := getRuntime(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runtime.GetContainerLabel(ctx, containerName, label)
+}
+
+// containerNamePrefix is the name prefix of containers created by yey
+const containerNamePrefix = "yey-"
+
+// isLegacyContainer returns whether container with given name and labels was created by an older
+// version of yey, which did not label containers and only identified them by their name prefix
+func isLegacyContainer(name string, labels map[string]string) bool {
+	if _, ok := labels[yey.LabelPath]; ok {
+		return false
+	}
+	return strings.HasPrefix(name, containerNamePrefix)
+}
+
+// containerLabels are the labels identifying yey containers
+var containerLabels = []string{yey.LabelPath, yey.LabelNames, yey.LabelHash, yey.LabelVersion, yey.LabelCreated}
+
+// Container represents a yey container, as identified by its labels
+type Container struct {
+	Name    string
+	Path    string
+	Names   []string
+	Hash    string
+	Version string
+	Created time.Time
+
+	// Legacy indicates an unlabeled container created by an older version of yey, which is only
+	// identified by its name (and associated with a Path when its name prefix matches one)
+	Legacy bool
+}
+
+// ContextName returns the context names container was created for, joined by spaces
+func (c Container) ContextName() string {
+	return strings.Join(c.Names, " ")
+}
+
+// newContainer returns the container with given name, as identified by given labels
+func newContainer(name string, labels map[string]string) (Container, error) {
+	container := Container{
+		Name:    name,
+		Path:    labels[yey.LabelPath],
+		Hash:    labels[yey.LabelHash],
+		Version: labels[yey.LabelVersion],
+	}
+	if err := json.Unmarshal([]byte(labels[yey.LabelNames]), &container.Names); err != nil {
+		return Container{}, fmt.Errorf("container %q: invalid %s label %q: %w", name, yey.LabelNames, labels[yey.LabelNames], err)
+	}
+	if created := labels[yey.LabelCreated]; created != "" {
+		var err error
+		container.Created, err = time.Parse(time.RFC3339, created)
+		if err != nil {
+			return Container{}, fmt.Errorf("container %q: invalid %s label %q: %w", name, yey.LabelCreated, created, err)
+		}
+	}
+	return container, nil
+}
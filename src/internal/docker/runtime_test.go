@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime is a runtime recording calls made to it, with containers in given status
+type fakeRuntime struct {
+	Runtime
+	status string
+	calls  []string
+	names  []string
+}
+
+func (r *fakeRuntime) GetContainerStatus(ctx context.Context, name string) (string, error) {
+	return r.status, nil
+}
+
+func (r *fakeRuntime) RunContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error {
+	call := "run " + strings.Join(yeyCtx.Cmd, " ")
+	if yeyCtx.Remove != nil && *yeyCtx.Remove {
+		call += " (rm)"
+	}
+	r.calls = append(r.calls, call)
+	r.names = append(r.names, name)
+	return nil
+}
+
+func (r *fakeRuntime) StartContainer(ctx context.Context, name string, options RunOptions) error {
+	call := "start"
+	if options.Detach {
+		call += " (detached)"
+	}
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *fakeRuntime) ExecContainer(ctx context.Context, yeyCtx yey.Context, name string, options RunOptions) error {
+	r.calls = append(r.calls, "exec "+strings.Join(yeyCtx.Cmd, " "))
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Status   string
+		OneOff   bool
+		Expected []string
+	}{
+		{Name: "new container", Status: "", Expected: []string{"run sh"}},
+		{Name: "stopped container", Status: "exited", Expected: []string{"start"}},
+		{Name: "running container", Status: "running", Expected: []string{"exec sh"}},
+		{Name: "command in new container", Status: "", OneOff: true, Expected: []string{"run sh (rm)"}},
+		{Name: "command in stopped container", Status: "exited", OneOff: true, Expected: []string{"start (detached)", "exec sh"}},
+		{Name: "command in running container", Status: "running", OneOff: true, Expected: []string{"exec sh"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			runtime := &fakeRuntime{status: c.Status}
+			runtimes[DockerRuntime] = runtime
+			defer delete(runtimes, DockerRuntime)
+
+			err := Run(context.Background(), yey.Context{Cmd: []string{"sh"}}, "yey-project-prod-123", RunOptions{OneOff: c.OneOff})
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.Expected, runtime.calls)
+		})
+	}
+}
+
+func TestRunOneOffWithoutContainer(t *testing.T) {
+	runtime := &fakeRuntime{}
+	runtimes[DockerRuntime] = runtime
+	defer delete(runtimes, DockerRuntime)
+
+	for i := 0; i < 2; i++ {
+		err := Run(context.Background(), yey.Context{Cmd: []string{"sh"}}, "yey-project-prod-123", RunOptions{OneOff: true})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, runtime.names, 2)
+	for _, name := range runtime.names {
+		assert.Regexp(t, `^yey-project-prod-123-run-[0-9a-f]{8}$`, name)
+	}
+	assert.NotEqual(t, runtime.names[0], runtime.names[1])
+}
+
+func TestRunUnexpectedStatus(t *testing.T) {
+	runtimes[DockerRuntime] = &fakeRuntime{status: "paused"}
+	defer delete(runtimes, DockerRuntime)
+
+	err := Run(context.Background(), yey.Context{}, "yey-project-prod-123", RunOptions{})
+
+	assert.EqualError(t, err, `container "yey-project-prod-123" in unexpected state "paused"`)
+}
+
+func TestMapLegacyContainers(t *testing.T) {
+	path := "/home/user/project/.yeyrc.yaml"
+	otherPath := "/home/user/other/.yeyrc.yaml"
+	containers := []Container{
+		{Name: yey.ContainerPathPrefix(path) + "-prod-123", Legacy: true},
+		{Name: yey.ContainerPathPrefix(otherPath) + "-dev-456", Legacy: true},
+		{Name: "yey-unknown-789", Legacy: true},
+	}
+
+	mapped := mapLegacyContainers(containers, []string{path, otherPath})
+
+	assert.Equal(t, []Container{
+		{Name: containers[0].Name, Path: path, Legacy: true},
+		{Name: containers[1].Name, Path: otherPath, Legacy: true},
+		{Name: "yey-unknown-789", Legacy: true},
+	}, mapped)
+}
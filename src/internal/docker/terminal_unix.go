@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package docker
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchTerminalSize calls given func whenever terminal gets resized, until returned func is called
+func watchTerminalSize(onResize func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	go func() {
+		for range signals {
+			onResize()
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+	}
+}
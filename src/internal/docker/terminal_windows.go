@@ -0,0 +1,6 @@
+package docker
+
+// watchTerminalSize does nothing on Windows, which has no SIGWINCH signal
+func watchTerminalSize(onResize func()) func() {
+	return func() {}
+}
@@ -0,0 +1,192 @@
+package yey
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandEnv replaces $VAR and ${VAR} references in given value with the values of corresponding
+// environment variables, also supporting ${VAR:-default} for default values and ${VAR:?message}
+// for required variables. A literal `$` can be escaped as `$$`.
+func expandEnv(value string) (string, error) {
+	var err error
+	expanded := os.Expand(value, func(expr string) string {
+		if expr == "$" {
+			return "$"
+		}
+		name, value, found := expr, "", false
+		if i := strings.Index(expr, ":-"); i != -1 {
+			name = expr[:i]
+			value, found = os.LookupEnv(name)
+			if !found || value == "" {
+				return expr[i+2:]
+			}
+			return value
+		}
+		if i := strings.Index(expr, ":?"); i != -1 {
+			name = expr[:i]
+			value, found = os.LookupEnv(name)
+			if (!found || value == "") && err == nil {
+				message := expr[i+2:]
+				if message == "" {
+					message = "parameter not set"
+				}
+				err = fmt.Errorf("%s: %s", name, message)
+			}
+			return value
+		}
+		return os.Getenv(name)
+	})
+	return expanded, err
+}
+
+// stringMapper transforms given value found at given key within a context file
+type stringMapper func(value string, key string) (string, error)
+
+// expandString expands environment variables in given value, reporting given key in case of error
+func expandString(value string, key string) (string, error) {
+	expanded, err := expandEnv(value)
+	if err != nil {
+		return "", fmt.Errorf("key %q: %w", key, err)
+	}
+	return expanded, nil
+}
+
+// mapStrings returns a copy of given values transformed by given mapper
+func mapStrings(values []string, key string, fn stringMapper) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	mapped := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if mapped[i], err = fn(value, fmt.Sprintf("%s[%d]", key, i)); err != nil {
+			return nil, err
+		}
+	}
+	return mapped, nil
+}
+
+// mapStringMap returns a copy of given map with all keys and values transformed by given mapper
+func mapStringMap(values map[string]string, key string, fn stringMapper) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	mapped := make(map[string]string, len(values))
+	for k, v := range values {
+		entryKey := key + "." + k
+		k, err := fn(k, entryKey)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = fn(v, entryKey); err != nil {
+			return nil, err
+		}
+		mapped[k] = v
+	}
+	return mapped, nil
+}
+
+// mapContext returns a copy of given context with all its string fields transformed by given mapper,
+// recursively into its variations. The key is the path of the context within its file, used for
+// reporting errors.
+func mapContext(context Context, key string, fn stringMapper) (Context, error) {
+	clone := context.Clone()
+	prefix := key
+	if prefix != "" {
+		prefix += "."
+	}
+
+	fields := []struct {
+		value *string
+		key   string
+	}{
+		{&clone.Description, "description"},
+		{&clone.Image, "image"},
+		{&clone.Network, "network"},
+		{&clone.Platform, "platform"},
+		{&clone.User, "user"},
+		{&clone.Runtime, "runtime"},
+		{&clone.EntryPoint, "entrypoint"},
+		{&clone.Build.Dockerfile, "build.dockerfile"},
+		{&clone.Build.Context, "build.context"},
+	}
+	for _, field := range fields {
+		var err error
+		if *field.value, err = fn(*field.value, prefix+field.key); err != nil {
+			return Context{}, err
+		}
+	}
+
+	var err error
+	if clone.Env, err = mapStringMap(clone.Env, prefix+"env", fn); err != nil {
+		return Context{}, err
+	}
+	if clone.Mounts, err = mapStringMap(clone.Mounts, prefix+"mounts", fn); err != nil {
+		return Context{}, err
+	}
+	if clone.Build.Args, err = mapStringMap(clone.Build.Args, prefix+"build.args", fn); err != nil {
+		return Context{}, err
+	}
+	if clone.Cmd, err = mapStrings(clone.Cmd, prefix+"cmd", fn); err != nil {
+		return Context{}, err
+	}
+	if clone.DockerArgs, err = mapStrings(clone.DockerArgs, prefix+"dockerArgs", fn); err != nil {
+		return Context{}, err
+	}
+
+	for _, variation := range clone.Variations {
+		for name, child := range variation.Contexts {
+			child, err := mapContext(child, fmt.Sprintf("%svariations.%s.%s", prefix, variation.Name, name), fn)
+			if err != nil {
+				return Context{}, err
+			}
+			variation.Contexts[name] = child
+		}
+	}
+
+	return clone, nil
+}
+
+// expandContext returns a copy of given context with environment variables expanded in all its fields,
+// recursively into its variations and their dynamic choice definitions. The key is the path of the
+// context within its file, used for reporting errors.
+func expandContext(context Context, key string) (Context, error) {
+	expanded, err := mapContext(context, key, expandString)
+	if err != nil {
+		return Context{}, err
+	}
+	if err := expandChoicesFrom(expanded, key); err != nil {
+		return Context{}, err
+	}
+	return expanded, nil
+}
+
+// expandChoicesFrom expands environment variables in the dynamic choice definitions of given context's
+// variations, recursively
+func expandChoicesFrom(context Context, key string) error {
+	prefix := key
+	if prefix != "" {
+		prefix += "."
+	}
+	for _, variation := range context.Variations {
+		variationKey := prefix + "variations." + variation.Name
+		if variation.ChoicesFrom != nil {
+			choicesKey := variationKey + "." + choicesFromProperty
+			var err error
+			if variation.ChoicesFrom.Command, err = expandString(variation.ChoicesFrom.Command, choicesKey+".command"); err != nil {
+				return err
+			}
+			if variation.ChoicesFrom.Template, err = expandContext(variation.ChoicesFrom.Template, choicesKey+".template"); err != nil {
+				return err
+			}
+		}
+		for name, child := range variation.Contexts {
+			if err := expandChoicesFrom(child, variationKey+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package yey
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("YEY_TEST_SET", "set")
+	os.Setenv("YEY_TEST_EMPTY", "")
+	defer os.Unsetenv("YEY_TEST_SET")
+	defer os.Unsetenv("YEY_TEST_EMPTY")
+
+	cases := []struct {
+		value    string
+		expected string
+		error    string
+	}{
+		{value: "$YEY_TEST_SET", expected: "set"},
+		{value: "${YEY_TEST_SET}-suffix", expected: "set-suffix"},
+		{value: "$YEY_TEST_UNSET", expected: ""},
+		{value: "${YEY_TEST_UNSET:-default value}", expected: "default value"},
+		{value: "${YEY_TEST_EMPTY:-default}", expected: "default"},
+		{value: "${YEY_TEST_SET:-default}", expected: "set"},
+		{value: "${YEY_TEST_SET:?must be set}", expected: "set"},
+		{value: "${YEY_TEST_UNSET:?must be set}", error: "YEY_TEST_UNSET: must be set"},
+		{value: "${YEY_TEST_EMPTY:?}", error: "YEY_TEST_EMPTY: parameter not set"},
+		{value: "echo $$HOME", expected: "echo $HOME"},
+		{value: "$$$YEY_TEST_SET", expected: "$set"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			actual, err := expandEnv(c.value)
+			if c.error != "" {
+				assert.EqualError(t, err, c.error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestExpandEnvInAllFields(t *testing.T) {
+	os.Setenv("YEY_TEST_VALUE", "value")
+	defer os.Unsetenv("YEY_TEST_VALUE")
+
+	contexts, err := parseContextFile("", []byte(`
+image: image:$YEY_TEST_VALUE
+network: $YEY_TEST_VALUE
+user: $YEY_TEST_VALUE
+mounts:
+  /local/$YEY_TEST_VALUE: /container/$YEY_TEST_VALUE
+cmd: [$YEY_TEST_VALUE]
+dockerArgs: [--label=$YEY_TEST_VALUE]
+build:
+  args:
+    ARG: $YEY_TEST_VALUE
+variations:
+  env:
+    dev:
+      variations:
+        sub:
+          child:
+            env:
+              CHILD: ${YEY_TEST_VALUE}
+`), nil, true)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext([]string{"dev", "child"})
+	require.NoError(t, err)
+	assert.Equal(t, "image:value", ctx.Image)
+	assert.Equal(t, "value", ctx.Network)
+	assert.Equal(t, "value", ctx.User)
+	assert.Equal(t, map[string]string{"/local/value": "/container/value"}, ctx.Mounts)
+	assert.Equal(t, []string{"value"}, ctx.Cmd)
+	assert.Equal(t, []string{"--label=value"}, ctx.DockerArgs)
+	assert.Equal(t, map[string]string{"ARG": "value"}, ctx.Build.Args)
+	assert.Equal(t, map[string]string{"CHILD": "value"}, ctx.Env)
+}
+
+func TestExpandEnvWithEscapedDollar(t *testing.T) {
+	contexts, err := parseContextFile("", []byte(`cmd: [sh, -c, "echo $$HOME"]`), nil, true)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sh", "-c", "echo $HOME"}, ctx.Cmd)
+}
+
+func TestExpandEnvWithMissingRequiredVariable(t *testing.T) {
+	_, err := parseContextFile("/project/.yeyrc.yaml", []byte(`
+variations:
+  env:
+    prod:
+      env:
+        TOKEN: ${YEY_TEST_UNSET:?token is required}
+`), nil, true)
+	assert.EqualError(t, err, `failed to expand environment variables in context file "/project/.yeyrc.yaml": key "variations.env.prod.env.TOKEN": YEY_TEST_UNSET: token is required`)
+}
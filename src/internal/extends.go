@@ -0,0 +1,126 @@
+package yey
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// extendsSeparator separates the variation and choice names of `extends` references (ie: `env/prod`)
+const extendsSeparator = "/"
+
+// extendsResolver flattens `extends` references of contexts, memoizing resolved targets
+type extendsResolver struct {
+	contexts Contexts
+	resolved map[string]Context
+}
+
+// resolveExtends returns a copy of these contexts where all contexts (including templates) declaring
+// `extends` have been merged on top of the context they extend, recursively
+func (c Contexts) resolveExtends() (Contexts, error) {
+	r := extendsResolver{contexts: c, resolved: make(map[string]Context)}
+	clone := c
+	clone.Templates = make(map[string]Context, len(c.Templates))
+	names := make([]string, 0, len(c.Templates))
+	for name := range c.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		template, err := r.resolveRef(name, nil)
+		if err != nil {
+			return Contexts{}, err
+		}
+		clone.Templates[name] = template
+	}
+
+	var err error
+	clone.Context, err = r.resolveContext(c.Context, "", nil)
+	if err != nil {
+		return Contexts{}, err
+	}
+	return clone, nil
+}
+
+// resolveContext returns given context merged on top of the context it extends, if any, recursively
+// into its variations. The key is the path of the context (ie: `env/prod`) and chain is the list of
+// references currently being resolved (for cycle detection).
+func (r *extendsResolver) resolveContext(context Context, key string, chain []string) (Context, error) {
+	resolved := context.Clone()
+	if context.Extends != "" {
+		base, err := r.resolveRef(context.Extends, append(chain, key))
+		if err != nil {
+			return Context{}, err
+		}
+		resolved.Extends = ""
+		resolved = base.Merge(resolved, true)
+	}
+
+	prefix := key
+	if prefix != "" {
+		prefix += extendsSeparator
+	}
+	for _, variation := range resolved.Variations {
+		for _, name := range variation.GetNames() {
+			child, err := r.resolveContext(variation.Contexts[name], prefix+variation.Name+extendsSeparator+name, chain)
+			if err != nil {
+				return Context{}, err
+			}
+			variation.Contexts[name] = child
+		}
+	}
+	return resolved, nil
+}
+
+// resolveRef returns the fully resolved context referenced by given template name or
+// `<variation>/<choice>` path
+func (r *extendsResolver) resolveRef(ref string, chain []string) (Context, error) {
+	if stringIsInStrings(ref, chain) {
+		return Context{}, fmt.Errorf("cycle detected in extends: %s", strings.Join(append(chain, ref), " -> "))
+	}
+	if resolved, ok := r.resolved[ref]; ok {
+		return resolved, nil
+	}
+
+	target, err := r.findRef(ref)
+	if err != nil {
+		referrer := "root context"
+		if len(chain) > 0 && chain[len(chain)-1] != "" {
+			referrer = fmt.Sprintf("context %q", chain[len(chain)-1])
+		}
+		return Context{}, fmt.Errorf("%s extends %q, which was not found: %w", referrer, ref, err)
+	}
+	resolved, err := r.resolveContext(target, ref, chain)
+	if err != nil {
+		return Context{}, err
+	}
+
+	// Name of target is not inherited
+	resolved.Name = ""
+	r.resolved[ref] = resolved
+	return resolved, nil
+}
+
+// findRef returns the unresolved context referenced by given template name or `<variation>/<choice>`
+// path, where nested choices are referenced by longer paths (ie: `env/prod/region/east`)
+func (r *extendsResolver) findRef(ref string) (Context, error) {
+	if template, ok := r.contexts.Templates[ref]; ok {
+		return template, nil
+	}
+
+	parts := strings.Split(ref, extendsSeparator)
+	if len(parts)%2 != 0 {
+		return Context{}, fmt.Errorf("expecting template name or <variation>/<choice> path")
+	}
+	context := r.contexts.Context
+	for i := 0; i < len(parts); i += 2 {
+		variation, ok := context.Variations.GetByName(parts[i])
+		if !ok {
+			return Context{}, fmt.Errorf("no variation %q", parts[i])
+		}
+		if context, ok = variation.Contexts[parts[i+1]]; !ok {
+			return Context{}, fmt.Errorf("no choice %q in variation %q", parts[i+1], parts[i])
+		}
+	}
+	return context, nil
+}
@@ -0,0 +1,96 @@
+package yey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseAndResolveExtends(t *testing.T, data string) (Contexts, error) {
+	t.Helper()
+	contexts, err := parseContextFile("", []byte(data), nil, true)
+	require.NoError(t, err)
+	return contexts.resolveExtends()
+}
+
+func TestResolveExtends(t *testing.T) {
+	contexts, err := parseAndResolveExtends(t, `
+templates:
+  cloud:
+    env:
+      CLOUD: "true"
+      REGION: us-east-1
+variations:
+  env:
+    prod:
+      extends: cloud
+      description: Production
+      env:
+        ENV: prod
+      cmd: [deploy]
+    stg:
+      extends: env/prod
+      env:
+        ENV: stg
+`)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext([]string{"stg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"CLOUD": "true", "REGION": "us-east-1", "ENV": "stg"}, ctx.Env)
+	assert.Equal(t, []string{"deploy"}, ctx.Cmd)
+	assert.Equal(t, "", ctx.Extends)
+	assert.Equal(t, []string{"Production"}, contexts.GetDescriptions([]string{"stg"}))
+	assert.Equal(t, [][]string{{"prod"}, {"stg"}}, contexts.GetCombos())
+}
+
+func TestResolveExtendsNestedChoice(t *testing.T) {
+	contexts, err := parseAndResolveExtends(t, `
+variations:
+  env:
+    prod:
+      variations:
+        region:
+          east:
+            env:
+              REGION: us-east-1
+    stg:
+      extends: env/prod/region/east
+`)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext([]string{"stg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"REGION": "us-east-1"}, ctx.Env)
+}
+
+func TestResolveExtendsDetectsCycles(t *testing.T) {
+	_, err := parseAndResolveExtends(t, `
+variations:
+  env:
+    prod:
+      extends: env/stg
+    stg:
+      extends: env/prod
+`)
+	assert.EqualError(t, err, "cycle detected in extends: env/prod -> env/stg -> env/prod")
+}
+
+func TestResolveExtendsReportsMissingTargets(t *testing.T) {
+	_, err := parseAndResolveExtends(t, `
+variations:
+  env:
+    stg:
+      extends: env/prodd
+`)
+	assert.EqualError(t, err, `context "env/stg" extends "env/prodd", which was not found: no choice "prodd" in variation "env"`)
+
+	_, err = parseAndResolveExtends(t, `
+variations:
+  env:
+    stg:
+      extends: base
+`)
+	assert.EqualError(t, err, `context "env/stg" extends "base", which was not found: expecting template name or <variation>/<choice> path`)
+}
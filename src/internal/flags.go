@@ -2,4 +2,15 @@ package yey
 
 var (
 	IsDryRun bool
+
+	// IsAssumeYes indicates that default choices should be used without prompting
+	IsAssumeYes bool
+
+	// IsExact indicates that context names passed as arguments must match choice names exactly,
+	// rather than also allowing case-insensitive and unambiguous prefix matches
+	IsExact bool
+
+	// IsStrict indicates that problems found while validating context files should be reported as
+	// errors, rather than warnings
+	IsStrict bool
 )
@@ -0,0 +1,165 @@
+package yey
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	gitURIPrefix     = "git+"
+	gitFetchedMarker = "yey-fetched"
+)
+
+// gitURI represents a reference to a file at a given ref within a git repository, expressed as
+// `git+<scheme>://<repo>#<ref>:<path>` (ie: `git+ssh://git@host/org/configs.git#v1.4:yey/.yeyrc.yaml`)
+type gitURI struct {
+	Repo string
+	Ref  string
+	Path string
+}
+
+// isGitURI returns whether given URI refers to a file within a git repository
+func isGitURI(uri string) bool {
+	return strings.HasPrefix(uri, gitURIPrefix+"file://") || strings.HasPrefix(uri, gitURIPrefix+"ssh://")
+}
+
+// parseGitURI parses given `git+` URI into its repo, ref and path components
+func parseGitURI(uri string) (gitURI, error) {
+	repoAndFragment := strings.TrimPrefix(uri, gitURIPrefix)
+	i := strings.LastIndex(repoAndFragment, "#")
+	if i == -1 {
+		return gitURI{}, fmt.Errorf("git uri %q must specify file to use as #<ref>:<path>", uri)
+	}
+	repo, fragment := repoAndFragment[:i], repoAndFragment[i+1:]
+	j := strings.Index(fragment, ":")
+	if j == -1 {
+		return gitURI{}, fmt.Errorf("git uri %q must specify file to use as #<ref>:<path>", uri)
+	}
+	ref, filePath := fragment[:j], strings.TrimPrefix(fragment[j+1:], "/")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if filePath == "" {
+		return gitURI{}, fmt.Errorf("git uri %q must specify file path", uri)
+	}
+	// Prevent ref or path from being interpreted as git options
+	if strings.HasPrefix(ref, "-") || strings.HasPrefix(filePath, "-") {
+		return gitURI{}, fmt.Errorf("git uri %q must not specify ref or path starting with %q", uri, "-")
+	}
+	return gitURI{
+		Repo: repo,
+		Ref:  ref,
+		Path: filePath,
+	}, nil
+}
+
+// String returns the `git+` URI representation of this reference
+func (u gitURI) String() string {
+	return fmt.Sprintf("%s%s#%s:%s", gitURIPrefix, u.Repo, u.Ref, u.Path)
+}
+
+// resolve returns the git URI of given path relative to the directory of this reference's file,
+// at same repo and ref
+func (u gitURI) resolve(relativePath string) gitURI {
+	resolved := u
+	resolved.Path = path.Join(path.Dir(u.Path), relativePath)
+	return resolved
+}
+
+// readContextFileFromGit reads the context file referenced by given git URI from local clone
+// cache, cloning or fetching repository as needed
+func readContextFileFromGit(uri string) ([]byte, error) {
+	ref, err := parseGitURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := syncGitRepo(ref.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve ref to commit first, so that it cannot be interpreted as anything else
+	commit, err := git("--git-dir", dir, "rev-parse", "--verify", "--quiet", ref.Ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref %q in git repo %q: %w", ref.Ref, ref.Repo, err)
+	}
+
+	data, err := git("--git-dir", dir, "show", strings.TrimSpace(string(commit))+":"+ref.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q at %q from git repo %q: %w", ref.Path, ref.Ref, ref.Repo, err)
+	}
+	return data, nil
+}
+
+// syncGitRepo ensures given repo is cloned in local cache and reasonably up-to-date, and returns
+// its local directory
+func syncGitRepo(repo string) (string, error) {
+	cacheDir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cacheDir, "git", hash(repo))
+	marker := filepath.Join(dir, gitFetchedMarker)
+
+	info, err := os.Stat(marker)
+	if errors.Is(err, os.ErrNotExist) {
+		// Initial clone
+		if IsOffline {
+			return "", fmt.Errorf("no cached clone of %q available in offline mode", repo)
+		}
+		Log("cloning git repo %s", repo)
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		os.RemoveAll(dir)
+		if _, err := git("clone", "--bare", "--quiet", "--", repo, dir); err != nil {
+			return "", fmt.Errorf("failed to clone git repo %q: %w", repo, err)
+		}
+		return dir, touch(marker)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if IsOffline || (!IsRefresh && time.Since(info.ModTime()) < CacheTTL) {
+		Log("using cached clone of %s", repo)
+		return dir, nil
+	}
+
+	// Update existing clone
+	Log("fetching git repo %s", repo)
+	if _, err := git("--git-dir", dir, "fetch", "--quiet", "--force", "--prune", "--tags", "origin", "+refs/heads/*:refs/heads/*"); err != nil {
+		Warn("failed to fetch git repo %q: %v: falling back to cached clone", repo, err)
+		return dir, nil
+	}
+	return dir, touch(marker)
+}
+
+// touch creates given file or updates its modification time
+func touch(path string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, nil, 0644)
+}
+
+// git runs git with given args and returns its stdout
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
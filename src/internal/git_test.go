@@ -0,0 +1,76 @@
+package yey
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+// createBareRepo creates a bare repo with a v1 tag and a more recent main branch
+func createBareRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	work := t.TempDir()
+	runGit(t, work, "init", "--quiet", "--initial-branch=main")
+	writeFile(t, work, "yey/base.yaml", "image: base_image\n")
+	writeFile(t, work, "yey/.yeyrc.yaml", "parent: base.yaml\nenv:\n  VERSION: v1\n")
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "--quiet", "-m", "v1")
+	runGit(t, work, "tag", "v1")
+	writeFile(t, work, "yey/.yeyrc.yaml", "parent: base.yaml\nenv:\n  VERSION: v2\n")
+	runGit(t, work, "commit", "--quiet", "-am", "v2")
+
+	bare := filepath.Join(t.TempDir(), "configs.git")
+	runGit(t, work, "clone", "--quiet", "--bare", work, bare)
+	return bare
+}
+
+func TestParseGitURI(t *testing.T) {
+	ref, err := parseGitURI("git+ssh://git@host/org/configs.git#v1.4:yey/.yeyrc.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, gitURI{Repo: "ssh://git@host/org/configs.git", Ref: "v1.4", Path: "yey/.yeyrc.yaml"}, ref)
+	assert.Equal(t, "git+ssh://git@host/org/configs.git#v1.4:yey/base.yaml", ref.resolve("base.yaml").String())
+
+	_, err = parseGitURI("git+ssh://git@host/org/configs.git")
+	assert.EqualError(t, err, `git uri "git+ssh://git@host/org/configs.git" must specify file to use as #<ref>:<path>`)
+
+	_, err = parseGitURI("git+ssh://git@host/org/configs.git#--output=/tmp/pwned:yey/.yeyrc.yaml")
+	assert.EqualError(t, err, `git uri "git+ssh://git@host/org/configs.git#--output=/tmp/pwned:yey/.yeyrc.yaml" must not specify ref or path starting with "-"`)
+
+	_, err = parseGitURI("git+ssh://git@host/org/configs.git#v1:-p")
+	assert.EqualError(t, err, `git uri "git+ssh://git@host/org/configs.git#v1:-p" must not specify ref or path starting with "-"`)
+}
+
+func TestParseContextFileWithGitParent(t *testing.T) {
+	withCacheDir(t)
+	repo := "git+file://" + createBareRepo(t)
+
+	contexts, err := parseContextFile("", []byte("parent: "+repo+"#v1:yey/.yeyrc.yaml\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "base_image", contexts.Image)
+	assert.Equal(t, "v1", contexts.Env["VERSION"])
+
+	contexts, err = parseContextFile("", []byte("parent: "+repo+"#main:yey/.yeyrc.yaml\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", contexts.Env["VERSION"])
+
+	// Cached clone is used when offline
+	IsOffline = true
+	contexts, err = parseContextFile("", []byte("parent: "+repo+"#v1:yey/.yeyrc.yaml\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", contexts.Env["VERSION"])
+}
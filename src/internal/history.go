@@ -0,0 +1,158 @@
+package yey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	historyFileName = ".yeyhistory.json"
+
+	// legacyLastNamesFileName is the file where older versions of yey recorded the last selected
+	// context names, regardless of project
+	legacyLastNamesFileName = ".yeylast"
+
+	// MaxHistoryEntries is the maximum number of selections remembered per project
+	MaxHistoryEntries = 20
+)
+
+// HistoryEntry represents context names selected by user at a given time
+type HistoryEntry struct {
+	Names []string  `json:"names"`
+	Time  time.Time `json:"time"`
+}
+
+// History represents the context names last selected by user, keyed by RC file path and
+// ordered from most to least recent
+type History map[string][]HistoryEntry
+
+// getHistoryFilePath returns the path of the history file in home dir
+func getHistoryFilePath() (string, error) {
+	return homedir.Expand("~/" + historyFileName)
+}
+
+// LoadHistory loads from home dir the context names previously selected by user for all projects
+func LoadHistory() (History, error) {
+	path, err := getHistoryFilePath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine history file path: %w", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return History{}, nil
+		}
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	history := History{}
+	if err := json.Unmarshal(data, &history); err != nil {
+		Warn("ignoring corrupted history file %q: %v", path, err)
+		return History{}, nil
+	}
+	return history, nil
+}
+
+// Save writes history to home dir, atomically so that concurrent invocations cannot corrupt it
+func (h History) Save() error {
+	path, err := getHistoryFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to determine history file path: %w", err)
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(path, data); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// UpdateHistory records given context names as most recent selection for RC file with given path,
+// while holding a lock on history file, so that concurrent invocations do not drop each other's
+// entries
+func UpdateHistory(path string, names []string, now time.Time) error {
+	historyPath, err := getHistoryFilePath()
+	if err != nil {
+		return fmt.Errorf("failed to determine history file path: %w", err)
+	}
+	unlock, err := lockFile(historyPath)
+	if err != nil {
+		return fmt.Errorf("failed to lock history file: %w", err)
+	}
+	defer unlock()
+
+	history, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	history.Add(path, names, now)
+	return history.Save()
+}
+
+// LoadLegacyLastNames loads the space-separated context names last selected with older versions of yey,
+// regardless of project, if any. Legacy file is left untouched, as it is only used as a fallback for
+// projects without history.
+func LoadLegacyLastNames() ([]string, error) {
+	legacyPath, err := homedir.Expand("~/" + legacyLastNamesFileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine last context names file path: %w", err)
+	}
+	data, err := os.ReadFile(legacyPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read last context names file: %w", err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// GetEntries returns the entries of RC file with given path, from most to least recent
+func (h History) GetEntries(path string) []HistoryEntry {
+	return h[path]
+}
+
+// GetLastNames returns the context names last selected for RC file with given path, if any
+func (h History) GetLastNames(path string) []string {
+	entries := h[path]
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[0].Names
+}
+
+// Add records given context names as most recent selection for RC file with given path, removing any
+// previous identical selection and keeping at most MaxHistoryEntries entries
+func (h History) Add(path string, names []string, now time.Time) {
+	entries := []HistoryEntry{{Names: names, Time: now}}
+	for _, entry := range h[path] {
+		if !stringsEqual(entry.Names, names) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > MaxHistoryEntries {
+		entries = entries[:MaxHistoryEntries]
+	}
+	h[path] = entries
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package yey
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	history := History{}
+	history.Add("/project1/.yeyrc.yaml", []string{"dev", "go"}, now)
+	history.Add("/project2/.yeyrc.yaml", []string{"prod"}, now.Add(time.Minute))
+	history.Add("/project1/.yeyrc.yaml", []string{"prod", "node"}, now.Add(2*time.Minute))
+	history.Add("/project1/.yeyrc.yaml", []string{"dev", "go"}, now.Add(3*time.Minute))
+
+	assert.Equal(t, []HistoryEntry{
+		{Names: []string{"dev", "go"}, Time: now.Add(3 * time.Minute)},
+		{Names: []string{"prod", "node"}, Time: now.Add(2 * time.Minute)},
+	}, history.GetEntries("/project1/.yeyrc.yaml"))
+	assert.Equal(t, []string{"prod"}, history.GetLastNames("/project2/.yeyrc.yaml"))
+	assert.Nil(t, history.GetLastNames("/project3/.yeyrc.yaml"))
+}
+
+func TestHistoryIsCapped(t *testing.T) {
+	history := History{}
+	for i := 0; i < MaxHistoryEntries+5; i++ {
+		history.Add("/project/.yeyrc.yaml", []string{fmt.Sprint(i)}, time.Now())
+	}
+
+	entries := history.GetEntries("/project/.yeyrc.yaml")
+	assert.Len(t, entries, MaxHistoryEntries)
+	assert.Equal(t, []string{fmt.Sprint(MaxHistoryEntries + 4)}, entries[0].Names)
+}
+
+func TestSaveAndLoadHistory(t *testing.T) {
+	dir := t.TempDir()
+	withHomeDir(t, dir)
+
+	history, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	history.Add("/my project/.yeyrc.yaml", []string{"name with spaces", "go"}, now)
+	require.NoError(t, history.Save())
+
+	loaded, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name with spaces", "go"}, loaded.GetLastNames("/my project/.yeyrc.yaml"))
+
+	// Corrupted file is ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, historyFileName), []byte("{"), 0644))
+	loaded, err = LoadHistory()
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestUpdateHistoryConcurrently(t *testing.T) {
+	withHomeDir(t, t.TempDir())
+
+	const count = 10
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			errs <- UpdateHistory(fmt.Sprintf("/project%d/.yeyrc.yaml", i), []string{"dev"}, time.Now())
+		}(i)
+	}
+	for i := 0; i < count; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	history, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Len(t, history, count)
+}
+
+func TestLegacyLastNamesAreLeftUntouched(t *testing.T) {
+	dir := t.TempDir()
+	withHomeDir(t, dir)
+	legacyPath := writeFile(t, dir, legacyLastNamesFileName, "prod go")
+
+	names, err := LoadLegacyLastNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod", "go"}, names)
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, UpdateHistory("/project/.yeyrc.yaml", []string{"dev", "go"}, now))
+
+	history, err := LoadHistory()
+	require.NoError(t, err)
+	assert.Equal(t, []HistoryEntry{{Names: []string{"dev", "go"}, Time: now}}, history.GetEntries("/project/.yeyrc.yaml"))
+	assert.FileExists(t, legacyPath)
+}
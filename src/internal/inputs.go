@@ -0,0 +1,140 @@
+package yey
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// inputPlaceholderFormat is the format of placeholders replaced by input values
+const inputPlaceholderFormat = "{{inputs.%s}}"
+
+// Input represents a free-form parameter provided by user at run time
+type Input struct {
+	// Name is used to reference input value as `{{inputs.<name>}}` and to pass it as `--input <name>=<value>`
+	Name string
+
+	// Prompt is the message displayed when prompting user for value (defaults to name)
+	Prompt string `yaml:",omitempty"`
+
+	// Default is the value used when user provides none
+	Default string `yaml:",omitempty"`
+
+	// Pattern is a regular expression that value must fully match
+	Pattern string `yaml:",omitempty"`
+
+	// Secret indicates that value must not be echoed when prompted
+	Secret bool `yaml:",omitempty"`
+
+	// Identity indicates that value affects container identity, so that a distinct container is
+	// used for each value
+	Identity bool `yaml:",omitempty"`
+
+	// Value is the value resolved at run time
+	Value string `yaml:"-"`
+}
+
+// GetPrompt returns the message to display when prompting user for value
+func (i Input) GetPrompt() string {
+	if i.Prompt != "" {
+		return i.Prompt
+	}
+	return i.Name
+}
+
+// Validate returns an error if given value does not match input's pattern
+func (i Input) Validate(value string) error {
+	if i.Pattern == "" {
+		return nil
+	}
+	regex, err := regexp.Compile("^(?:" + i.Pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q for input %q: %w", i.Pattern, i.Name, err)
+	}
+	if !regex.MatchString(value) {
+		return fmt.Errorf("invalid value for input %q: must match %q", i.Name, i.Pattern)
+	}
+	return nil
+}
+
+// mergeInputs returns source inputs appended to dest inputs, where source inputs override dest
+// inputs with same name
+func mergeInputs(dest, source []Input) []Input {
+	merged := append([]Input(nil), dest...)
+	for _, input := range source {
+		found := false
+		for i := range merged {
+			if merged[i].Name == input.Name {
+				merged[i] = input
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, input)
+		}
+	}
+	return merged
+}
+
+// ResolveInputs returns a copy of this context where given values have been assigned to inputs,
+// falling back to their defaults, after validating them
+func (c Context) ResolveInputs(values map[string]string) (Context, error) {
+	clone := c.Clone()
+	for name := range values {
+		if !clone.hasInput(name) {
+			return Context{}, fmt.Errorf("unknown input %q", name)
+		}
+	}
+	for i, input := range clone.Inputs {
+		value, ok := values[input.Name]
+		if !ok {
+			value = input.Default
+		}
+		if err := input.Validate(value); err != nil {
+			return Context{}, err
+		}
+		clone.Inputs[i].Value = value
+	}
+	return clone, nil
+}
+
+// ExpandInputs returns a copy of this context where input placeholders have been replaced with
+// resolved input values
+func (c Context) ExpandInputs() Context {
+	if len(c.Inputs) == 0 {
+		return c
+	}
+	var oldNew []string
+	for _, input := range c.Inputs {
+		oldNew = append(oldNew, fmt.Sprintf(inputPlaceholderFormat, input.Name), input.Value)
+	}
+	replacer := strings.NewReplacer(oldNew...)
+	expanded, _ := mapContext(c, "", func(value string, _ string) (string, error) {
+		return replacer.Replace(value), nil
+	})
+	return expanded
+}
+
+// getIdentityInputs returns the `name=value` pairs of identity-affecting inputs with non-empty
+// values, sorted by name
+func (c Context) getIdentityInputs() []string {
+	var pairs []string
+	for _, input := range c.Inputs {
+		if input.Identity && input.Value != "" {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", input.Name, input.Value))
+		}
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (c Context) hasInput(name string) bool {
+	for _, input := range c.Inputs {
+		if input.Name == name {
+			return true
+		}
+	}
+	return false
+}
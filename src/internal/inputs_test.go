@@ -0,0 +1,70 @@
+package yey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getInputsTestContext() Context {
+	return Context{
+		Name: "incident",
+		Env: map[string]string{
+			"TICKET": "{{inputs.ticket}}",
+			"TOKEN":  "{{inputs.token}}",
+		},
+		Mounts: map[string]string{
+			"/incidents/{{inputs.ticket}}": "/work",
+		},
+		Cmd: []string{"kubectl", "-n", "{{inputs.namespace}}"},
+		Inputs: []Input{
+			{Name: "ticket", Pattern: "INC-[0-9]+", Identity: true},
+			{Name: "namespace", Default: "default"},
+			{Name: "token", Secret: true},
+		},
+	}
+}
+
+func TestResolveAndExpandInputs(t *testing.T) {
+	ctx, err := getInputsTestContext().ResolveInputs(map[string]string{"ticket": "INC-42", "token": "s3cr3t"})
+	require.NoError(t, err)
+
+	expanded := ctx.ExpandInputs()
+	assert.Equal(t, map[string]string{"TICKET": "INC-42", "TOKEN": "s3cr3t"}, expanded.Env)
+	assert.Equal(t, map[string]string{"/incidents/INC-42": "/work"}, expanded.Mounts)
+	assert.Equal(t, []string{"kubectl", "-n", "default"}, expanded.Cmd)
+}
+
+func TestResolveInputsValidatesValues(t *testing.T) {
+	_, err := getInputsTestContext().ResolveInputs(map[string]string{"ticket": "INC-42x"})
+	assert.EqualError(t, err, `invalid value for input "ticket": must match "INC-[0-9]+"`)
+
+	_, err = getInputsTestContext().ResolveInputs(map[string]string{"ticket": "INC-1", "unknown": "value"})
+	assert.EqualError(t, err, `unknown input "unknown"`)
+}
+
+func TestContainerNameOnlyDependsOnIdentityInputs(t *testing.T) {
+	resolve := func(ticket, token string) string {
+		ctx, err := getInputsTestContext().ResolveInputs(map[string]string{"ticket": ticket, "token": token})
+		require.NoError(t, err)
+		return ContainerName("/project/.yeyrc.yaml", ctx)
+	}
+
+	name := resolve("INC-1", "token1")
+	assert.Equal(t, name, resolve("INC-1", "token2"))
+	assert.NotEqual(t, name, resolve("INC-2", "token1"))
+	assert.Contains(t, name, ConfigHash(getInputsTestContext().Clone()))
+}
+
+func TestMergeInputs(t *testing.T) {
+	parent := Context{Inputs: []Input{{Name: "ticket"}, {Name: "namespace", Default: "default"}}}
+	child := Context{Inputs: []Input{{Name: "namespace", Default: "kube-system"}, {Name: "token", Secret: true}}}
+
+	merged := parent.Merge(child, true)
+	assert.Equal(t, []Input{
+		{Name: "ticket"},
+		{Name: "namespace", Default: "kube-system"},
+		{Name: "token", Secret: true},
+	}, merged.Inputs)
+}
@@ -0,0 +1,48 @@
+package yey
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Labels stamped on containers to identify them
+const (
+	// LabelPath is the path of the RC file container was created from
+	LabelPath = "yey.path"
+
+	// LabelNames is the JSON-encoded list of context names container was created for
+	LabelNames = "yey.names"
+
+	// LabelHash is the hash of context configuration container was created with
+	LabelHash = "yey.hash"
+
+	// LabelVersion is the version of yey that created container
+	LabelVersion = "yey.version"
+
+	// LabelCreated is the time container was created at, in RFC 3339 format
+	LabelCreated = "yey.created"
+
+	// LabelContext is the resolved context container was created with, in YAML format
+	// (before input values get expanded, so that secrets are not recorded)
+	LabelContext = "yey.context"
+)
+
+// Version is the current version of yey, as set at build time
+var Version string
+
+// ContainerLabels returns the labels to stamp on container created from RC file at given path,
+// for given context names and resolved context
+func ContainerLabels(path string, names []string, context Context, now time.Time) map[string]string {
+	encodedNames, err := json.Marshal(names)
+	if err != nil {
+		panic(err)
+	}
+	return map[string]string{
+		LabelPath:    path,
+		LabelNames:   string(encodedNames),
+		LabelHash:    ConfigHash(context),
+		LabelVersion: Version,
+		LabelCreated: now.UTC().Format(time.RFC3339),
+		LabelContext: context.String(),
+	}
+}
@@ -0,0 +1,26 @@
+package yey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerLabels(t *testing.T) {
+	Version = "1.2.3"
+	defer func() { Version = "" }()
+
+	context := Context{Image: "alpine"}
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+	labels := ContainerLabels("/project/.yeyrc.yaml", []string{"prod", "go"}, context, now)
+
+	assert.Equal(t, map[string]string{
+		LabelPath:    "/project/.yeyrc.yaml",
+		LabelNames:   `["prod","go"]`,
+		LabelHash:    ConfigHash(context),
+		LabelVersion: "1.2.3",
+		LabelCreated: "2021-06-01T17:00:00Z",
+		LabelContext: context.String(),
+	}, labels)
+}
@@ -0,0 +1,212 @@
+package yey
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// unsetTag marks a value, choice or variation to be removed from what is inherited from parent
+	unsetTag = "!unset"
+
+	// replaceTag marks a list to replace, rather than be appended to, what is inherited from parent
+	replaceTag = "!replace"
+
+	// unsetValue is the internal representation of unset string values, kept through merges
+	// until context is fully resolved
+	unsetValue = "\x00unset"
+
+	// replaceValue is the internal marker prepended to lists that replace inherited lists
+	replaceValue = "\x00replace"
+
+	// excludeProperty is the key of exclude rules, which are lists of lists
+	excludeProperty = "exclude"
+
+	// removeProperty is the key of the remove flag, which cannot hold the unset marker as a boolean
+	removeProperty = "remove"
+)
+
+// resolveMergeTags recursively converts `!unset` and `!replace` tagged nodes into their internal
+// marker representations, so that they can be decoded into regular strings and lists
+func resolveMergeTags(n *yaml.Node) {
+	switch n.Tag {
+	case unsetTag:
+		n.Kind = yaml.ScalarNode
+		n.Tag = "!!str"
+		n.Value = unsetValue
+		n.Content = nil
+	case replaceTag:
+		resolveReplaceTag(n, newReplaceMarker())
+		return
+	}
+	for i, child := range n.Content {
+		// Marker of exclude rules must itself be a list, in order to be decoded as a rule
+		if n.Kind == yaml.MappingNode && i%2 == 1 && n.Content[i-1].Value == excludeProperty && child.Tag == replaceTag {
+			resolveReplaceTag(child, &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{newReplaceMarker()}})
+			continue
+		}
+		resolveMergeTags(child)
+	}
+}
+
+// newReplaceMarker returns a node representing the replace marker
+func newReplaceMarker() *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: replaceValue}
+}
+
+// resolveReplaceTag converts given `!replace` tagged node into a list starting with given marker
+func resolveReplaceTag(n *yaml.Node, marker *yaml.Node) {
+	n.Tag = "!!seq"
+	if n.Kind == yaml.ScalarNode {
+		// Allows `!replace` alone to clear inherited list
+		n.Kind = yaml.SequenceNode
+		n.Value = ""
+	}
+	n.Content = append([]*yaml.Node{marker}, n.Content...)
+}
+
+// isUnsetNode returns whether given node represents an unset value
+func isUnsetNode(n *yaml.Node) bool {
+	return n.Tag == unsetTag || (n.Kind == yaml.ScalarNode && n.Value == unsetValue)
+}
+
+// takeUnsetEntry removes entry with given key from given mapping node if it is unset, returning whether
+// it was, so that the marker does not get decoded into non-string values
+func takeUnsetEntry(n *yaml.Node, key string) bool {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key && isUnsetNode(n.Content[i+1]) {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// takeTemplatesUnsetEntries removes entry with given key from templates of given document node if it is
+// unset, returning the names of templates for which it was
+func takeTemplatesUnsetEntries(n *yaml.Node, key string) map[string]bool {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	_, templates := getMappingEntry(n, "templates")
+	if templates == nil || templates.Kind != yaml.MappingNode {
+		return nil
+	}
+	names := make(map[string]bool)
+	for i := 0; i+1 < len(templates.Content); i += 2 {
+		if takeUnsetEntry(templates.Content[i+1], key) {
+			names[templates.Content[i].Value] = true
+		}
+	}
+	return names
+}
+
+// mergeStrings returns source list appended to dest list, unless source is marked as replacing it
+func mergeStrings(dest, source []string) []string {
+	if len(source) > 0 && source[0] == replaceValue {
+		return append([]string(nil), source...)
+	}
+	merged := append([]string(nil), dest...)
+	return append(merged, source...)
+}
+
+// isReplaceRule returns whether given exclude rule is the marker of rules that replace inherited ones
+func isReplaceRule(rule []string) bool {
+	return len(rule) == 1 && rule[0] == replaceValue
+}
+
+// mergeRules returns source exclude rules appended to dest rules, unless source is marked as replacing them
+func mergeRules(dest, source [][]string) [][]string {
+	if len(source) > 0 && isReplaceRule(source[0]) {
+		return append([][]string(nil), source...)
+	}
+	merged := append([][]string(nil), dest...)
+	return append(merged, source...)
+}
+
+// rulesWithoutMarkers returns given exclude rules without replace marker
+func rulesWithoutMarkers(rules [][]string) [][]string {
+	if len(rules) > 0 && isReplaceRule(rules[0]) {
+		return rules[1:]
+	}
+	return rules
+}
+
+// stringsWithoutMarkers returns given list without replace marker
+func stringsWithoutMarkers(values []string) []string {
+	if len(values) > 0 && values[0] == replaceValue {
+		values = values[1:]
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// stringMapWithoutMarkers removes unset entries from given map in place
+func stringMapWithoutMarkers(values map[string]string) {
+	for key, value := range values {
+		if value == unsetValue {
+			delete(values, key)
+		}
+	}
+}
+
+// stringWithoutMarker returns empty string for unset value
+func stringWithoutMarker(value string) string {
+	if value == unsetValue {
+		return ""
+	}
+	return value
+}
+
+// withoutMarkers returns a copy of this fully resolved context, where unset values have been removed
+// and replace markers stripped
+func (c Context) withoutMarkers() Context {
+	clone := c.Clone()
+	clone.Image = stringWithoutMarker(clone.Image)
+	clone.Network = stringWithoutMarker(clone.Network)
+	clone.Platform = stringWithoutMarker(clone.Platform)
+	clone.User = stringWithoutMarker(clone.User)
+	clone.Runtime = stringWithoutMarker(clone.Runtime)
+	clone.EntryPoint = stringWithoutMarker(clone.EntryPoint)
+	clone.Build.Dockerfile = stringWithoutMarker(clone.Build.Dockerfile)
+	clone.Build.Context = stringWithoutMarker(clone.Build.Context)
+	stringMapWithoutMarkers(clone.Env)
+	stringMapWithoutMarkers(clone.Mounts)
+	stringMapWithoutMarkers(clone.Build.Args)
+	clone.Cmd = stringsWithoutMarkers(clone.Cmd)
+	clone.DockerArgs = stringsWithoutMarkers(clone.DockerArgs)
+	return clone
+}
+
+// prune returns a copy of these variations where unset variations and choices have been removed
+// recursively
+func (l Variations) prune() Variations {
+	var pruned Variations
+	for _, variation := range l {
+		if variation.unset {
+			continue
+		}
+		clone := variation.Clone()
+		clone.Default = stringWithoutMarker(clone.Default)
+		for name, context := range clone.Contexts {
+			if context.unset {
+				delete(clone.Contexts, name)
+				continue
+			}
+			context.Variations = context.Variations.prune()
+			clone.Contexts[name] = context
+		}
+		pruned = append(pruned, clone)
+	}
+	return pruned
+}
@@ -0,0 +1,180 @@
+package yey
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsetAndReplace(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", `
+image: parent_image
+build:
+  dockerfile: /Dockerfile
+env:
+  KEEP: parent
+  REMOVE: parent
+mounts:
+  /local/keep: /container/keep
+  /local/remove: /container/remove
+cmd: [parent, cmd]
+dockerArgs: [--parent]
+variations:
+  env:
+    dev:
+      env:
+        DEV: parent
+    stg:
+      env:
+        STG: parent
+    prod:
+      env:
+        PROD: parent
+  region:
+    east: {}
+`)
+
+	contexts, err := parseContextFile("", []byte(`
+parent: `+parent+`
+image: !unset
+env:
+  REMOVE: !unset
+mounts:
+  /local/remove: !unset
+cmd: !replace [child, cmd]
+dockerArgs: [--child]
+variations:
+  env:
+    dev:
+      env:
+        DEV: !unset
+        KEEP: !unset
+    stg: !unset
+  region: !unset
+`), nil, true)
+	require.NoError(t, err)
+	contexts.Variations = contexts.Variations.prune()
+
+	assert.Equal(t, [][]string{{"dev"}, {"prod"}}, contexts.GetCombos())
+
+	ctx, err := contexts.GetContext([]string{"prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "", ctx.Image)
+	assert.Equal(t, "/Dockerfile", ctx.Build.Dockerfile)
+	assert.Equal(t, map[string]string{"KEEP": "parent", "PROD": "parent"}, ctx.Env)
+	assert.Equal(t, map[string]string{"/local/keep": "/container/keep"}, ctx.Mounts)
+	assert.Equal(t, []string{"child", "cmd"}, ctx.Cmd)
+	assert.Equal(t, []string{"--parent", "--child"}, ctx.DockerArgs)
+
+	ctx, err = contexts.GetContext([]string{"dev"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{}, ctx.Env)
+}
+
+func TestReplaceWithEmptyList(t *testing.T) {
+	parent := Context{Cmd: []string{"parent"}}
+	child := Context{Cmd: []string{replaceValue}}
+
+	merged := parent.Merge(child, true)
+	assert.Nil(t, merged.withoutMarkers().Cmd)
+}
+
+func TestReplaceExclude(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", `
+exclude:
+  - [dev, west]
+variations:
+  env:
+    dev: {}
+    prod: {}
+  region:
+    east: {}
+    west: {}
+`)
+
+	contexts, err := parseContextFile("", []byte("parent: "+parent+"\nexclude: !replace\n  - [prod, east]\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"dev", "east"}, {"dev", "west"}, {"prod", "west"}}, contexts.GetCombos())
+
+	contexts, err = parseContextFile("", []byte("parent: "+parent+"\nexclude: !replace\n"), nil, true)
+	require.NoError(t, err)
+	assert.Len(t, contexts.GetCombos(), 4)
+
+	contexts, err = parseContextFile("", []byte("parent: "+parent+"\nexclude: [[prod, east]]\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"dev", "east"}, {"prod", "west"}}, contexts.GetCombos())
+}
+
+func TestUnsetUserAndRuntime(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", "user: 1000:1000\nruntime: podman\n")
+
+	contexts, err := parseContextFile("", []byte("parent: "+parent+"\nuser: !unset\nruntime: !unset\n"), nil, true)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", ctx.User)
+	assert.Equal(t, "", ctx.Runtime)
+}
+
+func TestUnsetInheritedBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "parent.yaml", "build:\n  dockerfile: Dockerfile\n  context: .\n")
+
+	contexts, err := parseContextFile(filepath.Join(dir, "project", yeyRCFileName), []byte(`
+parent: ../parent.yaml
+image: alpine
+build:
+  dockerfile: !unset
+  context: !unset
+`), nil, true)
+	require.NoError(t, err)
+
+	ctx, err := contexts.GetContext(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "alpine", ctx.Image)
+	assert.Equal(t, "", ctx.Build.Dockerfile)
+	assert.Equal(t, "", ctx.Build.Context)
+}
+
+func TestUnsetReservedKeysAndRemove(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", `
+remove: true
+variations:
+  env:
+    default: dev
+    multi: true
+    dev:
+      remove: false
+    prod: {}
+`)
+
+	contexts, err := parseContextFile("", []byte(`
+parent: `+parent+`
+remove: !unset
+variations:
+  env:
+    default: !unset
+    multi: !unset
+    dev:
+      remove: !unset
+`), nil, true)
+	require.NoError(t, err)
+	contexts.Variations = contexts.Variations.prune()
+
+	variation, ok := contexts.Variations.GetByName("env")
+	require.True(t, ok)
+	assert.Equal(t, "", variation.Default)
+	assert.False(t, variation.Multi)
+	assert.Equal(t, []string{"dev", "prod"}, variation.GetNames())
+
+	ctx, err := contexts.GetContext([]string{"dev"})
+	require.NoError(t, err)
+	assert.Nil(t, ctx.Remove)
+}
@@ -0,0 +1,156 @@
+package yey
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// migration upgrades given context file root mapping node from one version to the next
+type migration func(root *yaml.Node) error
+
+// migrations is the list of successive migrations, where the one at index i upgrades documents
+// from version i to version i+1
+var migrations = []migration{
+	migrateParentToParents,
+}
+
+// currentVersion is the latest context file version
+var currentVersion = len(migrations)
+
+// migrateParentToParents (v0 -> v1) replaces the single `parent` property with a `parents` list
+func migrateParentToParents(root *yaml.Node) error {
+	keyNode, valueNode := getMappingEntry(root, "parent")
+	if keyNode == nil {
+		return nil
+	}
+	if parents, _ := getMappingEntry(root, "parents"); parents != nil {
+		return fmt.Errorf("cannot specify both %q and %q properties", "parent", "parents")
+	}
+	keyNode.Value = "parents"
+	*valueNode = yaml.Node{
+		Kind:    yaml.SequenceNode,
+		Tag:     "!!seq",
+		Content: []*yaml.Node{cloneNode(valueNode)},
+	}
+	return nil
+}
+
+// migrate upgrades given context file document node in place to current version and returns
+// whether any migration was applied
+func migrate(doc *yaml.Node) (bool, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return false, nil
+		}
+		root = root.Content[0]
+	}
+	if root.Kind == 0 {
+		return false, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return false, fmt.Errorf("expecting map at root of context file at line %d, column %d", root.Line, root.Column)
+	}
+
+	version := 0
+	if _, versionNode := getMappingEntry(root, "version"); versionNode != nil {
+		var err error
+		version, err = strconv.Atoi(versionNode.Value)
+		if err != nil {
+			return false, fmt.Errorf("invalid context file version %q at line %d, column %d", versionNode.Value, versionNode.Line, versionNode.Column)
+		}
+	}
+	if version > currentVersion {
+		return false, fmt.Errorf("unsupported context file version %d (latest supported version is %d): please upgrade yey", version, currentVersion)
+	}
+	if version < 0 {
+		return false, fmt.Errorf("unsupported context file version %d", version)
+	}
+	if version == currentVersion {
+		return false, nil
+	}
+
+	for ; version < currentVersion; version++ {
+		Log("migrating context file from version %d to %d", version, version+1)
+		if err := migrations[version](root); err != nil {
+			return false, fmt.Errorf("failed to migrate context file from version %d to %d: %w", version, version+1, err)
+		}
+	}
+	setMappingEntry(root, "version", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(currentVersion)})
+	return true, nil
+}
+
+// MigrateContextFile rewrites context file at given path to current version, preserving comments,
+// and returns whether any migration was needed. In dry-run mode, migrated file is output to stdout
+// instead.
+func MigrateContextFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read context file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false, fmt.Errorf("failed to decode context file %q: %w", path, err)
+	}
+
+	migrated, err := migrate(&doc)
+	if err != nil {
+		return false, fmt.Errorf("failed to migrate context file %q: %w", path, err)
+	}
+	if !migrated {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return false, fmt.Errorf("failed to encode context file %q: %w", path, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return false, err
+	}
+
+	if IsDryRun {
+		fmt.Print(buf.String())
+		return true, nil
+	}
+	if err := writeFileAtomically(path, buf.Bytes()); err != nil {
+		return false, fmt.Errorf("failed to write context file %q: %w", path, err)
+	}
+	return true, nil
+}
+
+// getMappingEntry returns the key and value nodes of given mapping node for given key, or nils if not found
+func getMappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// setMappingEntry sets value node of given key in mapping node, inserting it first if not found
+func setMappingEntry(mapping *yaml.Node, key string, value *yaml.Node) {
+	if _, existing := getMappingEntry(mapping, key); existing != nil {
+		existing.Kind = value.Kind
+		existing.Tag = value.Tag
+		existing.Value = value.Value
+		existing.Content = value.Content
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	mapping.Content = append([]*yaml.Node{keyNode, value}, mapping.Content...)
+}
+
+// cloneNode returns a shallow copy of given node
+func cloneNode(n *yaml.Node) *yaml.Node {
+	clone := *n
+	return &clone
+}
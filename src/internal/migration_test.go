@@ -0,0 +1,60 @@
+package yey
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateContextFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, yeyRCFileName, `# Team config
+parent: ../org.yaml # shared org settings
+
+# Default image
+image: alpine
+`)
+
+	migrated, err := MigrateContextFile(path)
+	require.NoError(t, err)
+	assert.True(t, migrated)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `version: 1
+# Team config
+parents:
+  - ../org.yaml # shared org settings
+# Default image
+image: alpine
+`, string(data))
+
+	// Already up-to-date
+	migrated, err = MigrateContextFile(path)
+	require.NoError(t, err)
+	assert.False(t, migrated)
+}
+
+func TestParseContextFileMigratesInMemory(t *testing.T) {
+	dir := t.TempDir()
+	parent := writeFile(t, dir, "parent.yaml", "image: parent_image\n")
+
+	contexts, err := parseContextFile("", []byte("parent: "+parent+"\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "parent_image", contexts.Image)
+}
+
+func TestParseContextFileWithUnsupportedVersion(t *testing.T) {
+	_, err := parseContextFile("", []byte("version: 99\n"), nil, true)
+	assert.EqualError(t, err, "unsupported context file version 99 (latest supported version is 1): please upgrade yey")
+}
+
+func TestParseEmptyContextFile(t *testing.T) {
+	for _, data := range []string{"", "# comment\n"} {
+		contexts, err := parseContextFile("", []byte(data), nil, true)
+		require.NoError(t, err, "data: %q", data)
+		assert.Empty(t, contexts.Image)
+	}
+}
@@ -0,0 +1,169 @@
+package yey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// cacheEntry represents the metadata persisted alongside a cached remote file
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// getCachePaths returns the paths of the data and metadata files caching given url
+func getCachePaths(url string) (string, string, error) {
+	dir, err := GetCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(dir, "http", hash(url))
+	return base + ".yaml", base + ".json", nil
+}
+
+// readCache returns the cached data and metadata for given url, or nil data if not cached
+func readCache(url string) ([]byte, cacheEntry, error) {
+	dataPath, metaPath, err := getCachePaths(url)
+	if err != nil {
+		return nil, cacheEntry{}, err
+	}
+
+	metaBytes, err := os.ReadFile(metaPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, cacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, cacheEntry{}, fmt.Errorf("failed to read cache metadata: %w", err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(metaBytes, &entry); err != nil {
+		return nil, cacheEntry{}, fmt.Errorf("failed to decode cache metadata %q: %w", metaPath, err)
+	}
+
+	data, err := os.ReadFile(dataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, cacheEntry{}, nil
+	}
+	if err != nil {
+		return nil, cacheEntry{}, fmt.Errorf("failed to read cached file: %w", err)
+	}
+	return data, entry, nil
+}
+
+// writeCache persists data and metadata for given url
+func writeCache(data []byte, entry cacheEntry) error {
+	dataPath, metaPath, err := getCachePaths(entry.URL)
+	if err != nil {
+		return err
+	}
+	metaBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(dataPath, data); err != nil {
+		return fmt.Errorf("failed to write cached file: %w", err)
+	}
+	if err := writeFileAtomically(metaPath, metaBytes); err != nil {
+		return fmt.Errorf("failed to write cache metadata: %w", err)
+	}
+	return nil
+}
+
+// readContextFileFromNetwork reads the contextfile from the network over http, using local cache
+// when fresh enough, revalidating it otherwise and falling back to it when network fails
+func readContextFileFromNetwork(url string) ([]byte, error) {
+	cached, entry, err := readCache(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsOffline {
+		if cached == nil {
+			return nil, fmt.Errorf("no cached copy of %q available in offline mode", url)
+		}
+		Log("using cached copy of %s (offline)", url)
+		return cached, nil
+	}
+
+	if cached != nil && !IsRefresh && time.Since(entry.FetchedAt) < CacheTTL {
+		Log("using cached copy of %s (fetched at %s)", url, entry.FetchedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+
+	// Only revalidate cached copy when not forcing a refresh
+	revalidated := cached
+	if IsRefresh {
+		revalidated = nil
+	}
+
+	data, err := fetch(url, revalidated, entry)
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		Warn("%v: falling back to cached copy fetched at %s", err, entry.FetchedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+	return data, nil
+}
+
+// fetch downloads given url, revalidating the cached copy, if any, and updating cache
+func fetch(url string, cached []byte, entry cacheEntry) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", url, err)
+	}
+	if cached != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching context file from network: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		Log("cached copy of %s still valid", url)
+		entry.FetchedAt = time.Now()
+		if err := writeCache(cached, entry); err != nil {
+			Warn("%v", err)
+		}
+		return cached, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed fetching context file from network: unexpected status %q", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading context file from network: %w", err)
+	}
+
+	entry = cacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+	if err := writeCache(data, entry); err != nil {
+		Warn("%v", err)
+	}
+	return data, nil
+}
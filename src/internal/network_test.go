@@ -0,0 +1,95 @@
+package yey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withCacheDir(t *testing.T) {
+	t.Helper()
+	os.Setenv(cacheDirEnvVar, t.TempDir())
+	t.Cleanup(func() {
+		os.Unsetenv(cacheDirEnvVar)
+		IsOffline = false
+		IsRefresh = false
+		CacheTTL = time.Hour
+	})
+}
+
+func TestReadContextFileFromNetwork(t *testing.T) {
+	withCacheDir(t)
+
+	requests := 0
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("image: remote"))
+	}))
+	defer server.Close()
+
+	// Initial fetch
+	data, err := readContextFileFromNetwork(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image: remote", string(data))
+	assert.Equal(t, 1, requests)
+
+	// Fresh cache
+	data, err = readContextFileFromNetwork(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image: remote", string(data))
+	assert.Equal(t, 1, requests)
+
+	// Expired cache gets revalidated
+	CacheTTL = 0
+	data, err = readContextFileFromNetwork(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image: remote", string(data))
+	assert.Equal(t, 2, requests)
+
+	// Network failure falls back to cache
+	status = http.StatusInternalServerError
+	data, err = readContextFileFromNetwork(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image: remote", string(data))
+	assert.Equal(t, 3, requests)
+
+	// Offline mode never hits network
+	IsOffline = true
+	data, err = readContextFileFromNetwork(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image: remote", string(data))
+	assert.Equal(t, 3, requests)
+}
+
+func TestReadContextFileFromNetworkFailsOnNon2xx(t *testing.T) {
+	withCacheDir(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := readContextFileFromNetwork(server.URL)
+	assert.EqualError(t, err, `failed fetching context file from network: unexpected status "404 Not Found"`)
+}
+
+func TestReadContextFileFromNetworkOfflineWithoutCache(t *testing.T) {
+	withCacheDir(t)
+	IsOffline = true
+
+	_, err := readContextFileFromNetwork("http://example.com/.yeyrc.yaml")
+	assert.EqualError(t, err, `no cached copy of "http://example.com/.yeyrc.yaml" available in offline mode`)
+}
@@ -0,0 +1,151 @@
+package yey
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v3"
+)
+
+const sha256Prefix = "sha256:"
+
+// ParentRef represents a reference to a parent context file, which can be specified either as
+// a plain URI string or as a map with optional integrity pin and detached signature
+type ParentRef struct {
+	URI       string
+	SHA256    string `yaml:"sha256,omitempty"`
+	Signature string `yaml:"signature,omitempty"`
+}
+
+func (r *ParentRef) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		r.URI = n.Value
+		return nil
+	}
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("expecting string or map for parent at line %d, column %d", n.Line, n.Column)
+	}
+	type plain ParentRef
+	var ref plain
+	if err := n.Decode(&ref); err != nil {
+		return err
+	}
+	if ref.URI == "" {
+		return fmt.Errorf("missing uri for parent at line %d, column %d", n.Line, n.Column)
+	}
+	*r = ParentRef(ref)
+	return nil
+}
+
+// String returns the URI of referenced parent
+func (r ParentRef) String() string {
+	return r.URI
+}
+
+// isTrusted returns whether referenced parent is either local or has its integrity or signature verified,
+// in which case it may run commands on host
+func (r ParentRef) isTrusted() bool {
+	return !isRemote(r.URI) || r.SHA256 != "" || r.Signature != ""
+}
+
+// verifyIntegrity checks that data matches the sha256 pin of this reference, if any
+func (r ParentRef) verifyIntegrity(data []byte) error {
+	if r.SHA256 == "" {
+		return nil
+	}
+	expected := strings.ToLower(strings.TrimPrefix(r.SHA256, sha256Prefix))
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if actual != expected {
+		return fmt.Errorf("integrity check failed: expected sha256 %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// verifySignature checks that data has a valid detached signature, if any is specified,
+// from one of the trusted public keys listed in home context file
+func (r ParentRef) verifySignature(data []byte) error {
+	if r.Signature == "" {
+		return nil
+	}
+
+	sigData, err := readContextFileFromURI(r.Signature)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	sig, err := decodeSignature(sigData)
+	if err != nil {
+		return err
+	}
+
+	keys, err := loadTrustedKeys()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("signature verification failed: no trusted keys configured in home %s", yeyRCFileName)
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature verification failed: no trusted key matches signature %q", r.Signature)
+}
+
+// decodeSignature accepts either a raw or base64-encoded ed25519 signature
+func decodeSignature(data []byte) ([]byte, error) {
+	if len(data) == ed25519.SignatureSize {
+		return data, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature size %d", len(sig))
+	}
+	return sig, nil
+}
+
+// loadTrustedKeys returns the ed25519 public keys listed under `trustedKeys` in home context file
+func loadTrustedKeys() ([]ed25519.PublicKey, error) {
+	dir, err := homedir.Dir()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine home dir: %w", err)
+	}
+	path := filepath.Join(dir, yeyRCFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys from %q: %w", path, err)
+	}
+
+	var file struct {
+		TrustedKeys []string `yaml:"trustedKeys"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode trusted keys from %q: %w", path, err)
+	}
+
+	keys := make([]ed25519.PublicKey, 0, len(file.TrustedKeys))
+	for _, value := range file.TrustedKeys {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key %q in %q", value, path)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, nil
+}
@@ -0,0 +1,101 @@
+package yey
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func withHomeDir(t *testing.T, dir string) {
+	t.Helper()
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	homedir.DisableCache = true
+	t.Cleanup(func() {
+		os.Setenv("HOME", home)
+		homedir.DisableCache = false
+	})
+}
+
+func TestUnmarshalParentRef(t *testing.T) {
+	var file ContextFile
+	require.NoError(t, yaml.Unmarshal([]byte(`
+parents:
+  - ./plain.yaml
+  - uri: ./pinned.yaml
+    sha256: sha256:abc
+    signature: ./pinned.yaml.sig
+`), &file))
+
+	assert.Equal(t, []ParentRef{
+		{URI: "./plain.yaml"},
+		{URI: "./pinned.yaml", SHA256: "sha256:abc", Signature: "./pinned.yaml.sig"},
+	}, file.Parents)
+}
+
+func TestParentIntegrityPin(t *testing.T) {
+	dir := t.TempDir()
+	content := "image: parent_image\n"
+	path := writeFile(t, dir, "parent.yaml", content)
+	sum := sha256.Sum256([]byte(content))
+
+	contexts, err := readAndParseContextFileFromURI(ParentRef{URI: path, SHA256: "sha256:" + hex.EncodeToString(sum[:])}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "parent_image", contexts.Image)
+
+	_, err = readAndParseContextFileFromURI(ParentRef{URI: path, SHA256: "sha256:0000"}, nil)
+	assert.EqualError(t, err, `refusing to use context file "`+path+`": integrity check failed: expected sha256 0000, got `+hex.EncodeToString(sum[:]))
+}
+
+func TestParentSignature(t *testing.T) {
+	dir := t.TempDir()
+	withHomeDir(t, dir)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	content := "image: parent_image\n"
+	path := writeFile(t, dir, "parent.yaml", content)
+	goodSig := writeFile(t, dir, "good.sig", base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(content))))
+	badSig := writeFile(t, dir, "bad.sig", base64.StdEncoding.EncodeToString(ed25519.Sign(otherKey, []byte(content))))
+
+	// No trusted keys
+	_, err = readAndParseContextFileFromURI(ParentRef{URI: path, Signature: goodSig}, nil)
+	assert.EqualError(t, err, `refusing to use context file "`+path+`": signature verification failed: no trusted keys configured in home .yeyrc.yaml`)
+
+	writeFile(t, dir, yeyRCFileName, "trustedKeys:\n  - "+base64.StdEncoding.EncodeToString(publicKey)+"\n")
+
+	contexts, err := readAndParseContextFileFromURI(ParentRef{URI: path, Signature: goodSig}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "parent_image", contexts.Image)
+
+	_, err = readAndParseContextFileFromURI(ParentRef{URI: path, Signature: badSig}, nil)
+	assert.EqualError(t, err, `refusing to use context file "`+path+`": signature verification failed: no trusted key matches signature "`+badSig+`"`)
+}
+
+func TestLoadTrustedKeysReportsReadErrors(t *testing.T) {
+	dir := t.TempDir()
+	withHomeDir(t, dir)
+
+	keys, err := loadTrustedKeys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// Home context file that cannot be read
+	path := filepath.Join(dir, yeyRCFileName)
+	require.NoError(t, os.Mkdir(path, 0755))
+	_, err = loadTrustedKeys()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to read trusted keys from "`+path+`"`)
+}
@@ -0,0 +1,86 @@
+package yey
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+const schemaURL = "http://json-schema.org/draft-07/schema#"
+
+// schema represents a JSON Schema node
+type schema map[string]interface{}
+
+// JSONSchema returns a JSON Schema describing context files, generated from the ContextFile and
+// Context structs, for editor validation and completion
+func JSONSchema() ([]byte, error) {
+	root := schemaForType(reflect.TypeOf(ContextFile{}))
+	root["$schema"] = schemaURL
+	root["title"] = "yey context file"
+	root["definitions"] = schema{
+		"context": schemaForStruct(contextType),
+	}
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// schemaForType returns the JSON Schema for values of given type, as decoded by yaml
+func schemaForType(t reflect.Type) schema {
+	switch t {
+	case contextType:
+		return schema{"$ref": "#/definitions/context"}
+	case variationsType:
+		return schema{
+			"type": "object",
+			"additionalProperties": schema{
+				"type": "object",
+				"properties": schema{
+					defaultProperty:     schema{"type": "string"},
+					multiProperty:       schema{"type": "boolean"},
+					choicesFromProperty: schemaForStruct(choicesType),
+				},
+				"additionalProperties": schema{"$ref": "#/definitions/context"},
+			},
+		}
+	case parentRefType:
+		return schema{
+			"oneOf": []schema{
+				{"type": "string"},
+				schemaForStruct(t),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaForType(t.Elem())
+	case reflect.Struct:
+		return schemaForStruct(t)
+	case reflect.Map:
+		return schema{
+			"type":                 "object",
+			"additionalProperties": schemaForType(t.Elem()),
+		}
+	case reflect.Slice:
+		return schema{
+			"type":  "array",
+			"items": schemaForType(t.Elem()),
+		}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int:
+		return schema{"type": "integer"}
+	default:
+		return schema{"type": "string"}
+	}
+}
+
+func schemaForStruct(t reflect.Type) schema {
+	properties := schema{}
+	for _, field := range getYAMLFields(t) {
+		properties[field.Name] = schemaForType(field.Type)
+	}
+	return schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
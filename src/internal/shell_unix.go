@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package yey
+
+import "os/exec"
+
+// shellCommand returns the command running given command line through host's POSIX shell
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,14 @@
+package yey
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand returns the command running given command line through cmd.exe, which is passed the
+// command line verbatim, as it does not follow the quoting rules Go escapes arguments with
+func shellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: "cmd /C " + command}
+	return cmd
+}
@@ -0,0 +1,341 @@
+package yey
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic represents a problem found in a context file, at a given location
+type Diagnostic struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String returns the diagnostic formatted as `file:line:column: message`
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Message)
+}
+
+// Diagnostics represents a list of problems found in context files
+type Diagnostics []Diagnostic
+
+// Error returns all diagnostics, one per line
+func (l Diagnostics) Error() string {
+	lines := make([]string, 0, len(l))
+	for _, d := range l {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yamlField represents a struct field as seen by yaml decoder
+type yamlField struct {
+	Name string
+	Type reflect.Type
+}
+
+// getYAMLFields returns the fields of given struct type as seen by yaml decoder, including those
+// of inlined structs
+func getYAMLFields(t reflect.Type) []yamlField {
+	var fields []yamlField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		inline := false
+		for _, flag := range parts[1:] {
+			if flag == "inline" {
+				inline = true
+			}
+		}
+		if inline {
+			fields = append(fields, getYAMLFields(field.Type)...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields = append(fields, yamlField{Name: name, Type: field.Type})
+	}
+	return fields
+}
+
+var (
+	contextType    = reflect.TypeOf(Context{})
+	variationsType = reflect.TypeOf(Variations{})
+	parentRefType  = reflect.TypeOf(ParentRef{})
+	choicesType    = reflect.TypeOf(ChoicesFrom{})
+)
+
+// validator accumulates diagnostics found while walking a context file's yaml nodes
+type validator struct {
+	path        string
+	diagnostics Diagnostics
+
+	// inherits indicates that context file inherits from other files, which may declare choices
+	inherits bool
+
+	// extending is the number of enclosing contexts extending other contexts, which may declare choices
+	extending int
+}
+
+func (v *validator) report(n *yaml.Node, format string, a ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Path:    v.path,
+		Line:    n.Line,
+		Column:  n.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// validate checks given node against given type, reporting problems found under given key
+func (v *validator) validate(n *yaml.Node, t reflect.Type, key string) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	switch t {
+	case variationsType:
+		v.validateVariations(n, key)
+		return
+	case parentRefType:
+		if n.Kind != yaml.ScalarNode {
+			v.validateStruct(n, t, key)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		// Optional values may be unset, to clear what is inherited
+		if isUnsetNode(n) {
+			return
+		}
+		v.validate(n, t.Elem(), key)
+	case reflect.Struct:
+		v.validateStruct(n, t, key)
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			v.report(n, "expecting map for %q", key)
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v.validate(n.Content[i+1], t.Elem(), key+"."+n.Content[i].Value)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			v.report(n, "expecting list for %q", key)
+			return
+		}
+		for i, item := range n.Content {
+			if item.Kind == yaml.ScalarNode && item.Value == replaceValue {
+				continue
+			}
+			v.validate(item, t.Elem(), fmt.Sprintf("%s[%d]", key, i))
+		}
+	case reflect.String:
+		if n.Kind != yaml.ScalarNode {
+			v.report(n, "expecting string for %q", key)
+		}
+	case reflect.Bool:
+		var value bool
+		if n.Kind != yaml.ScalarNode || n.Decode(&value) != nil {
+			v.report(n, "expecting boolean for %q", key)
+		}
+	case reflect.Int64:
+		var value time.Duration
+		if t == reflect.TypeOf(value) && (n.Kind != yaml.ScalarNode || n.Decode(&value) != nil) {
+			v.report(n, "expecting duration (ie: 30s, 5m, 1h) for %q", key)
+		}
+	case reflect.Int:
+		var value int
+		if n.Kind != yaml.ScalarNode || n.Decode(&value) != nil {
+			v.report(n, "expecting integer for %q", key)
+		}
+	}
+}
+
+func (v *validator) validateStruct(n *yaml.Node, t reflect.Type, key string) {
+	if n.Kind != yaml.MappingNode {
+		v.report(n, "expecting map for %q", key)
+		return
+	}
+	if _, extends := getMappingEntry(n, "extends"); extends != nil {
+		v.extending++
+		defer func() { v.extending-- }()
+	}
+	fields := getYAMLFields(t)
+	prefix := key
+	if prefix != "" {
+		prefix += "."
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		name := n.Content[i].Value
+		var field *yamlField
+		for j := range fields {
+			if fields[j].Name == name {
+				field = &fields[j]
+				break
+			}
+		}
+		if field == nil {
+			v.report(n.Content[i], "unknown field %q%s", prefix+name, suggestField(name, fields))
+			continue
+		}
+		v.validate(n.Content[i+1], field.Type, prefix+name)
+	}
+}
+
+func (v *validator) validateVariations(n *yaml.Node, key string) {
+	if n.Kind != yaml.MappingNode {
+		v.report(n, "expecting map of variations for %q", key)
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		variationKey := key + "." + n.Content[i].Value
+		variation := n.Content[i+1]
+		if isUnsetNode(variation) {
+			continue
+		}
+		if variation.Kind != yaml.MappingNode {
+			v.report(variation, "expecting map of choices for variation %q", variationKey)
+			continue
+		}
+		for j := 0; j+1 < len(variation.Content); j += 2 {
+			choice := variation.Content[j+1]
+			if isUnsetNode(choice) {
+				continue
+			}
+			if variation.Content[j].Value == defaultProperty && choice.Kind == yaml.ScalarNode {
+				continue
+			}
+			if variation.Content[j].Value == multiProperty && choice.Kind == yaml.ScalarNode {
+				v.validate(choice, reflect.TypeOf(true), variationKey+"."+multiProperty)
+				continue
+			}
+			if variation.Content[j].Value == choicesFromProperty && choice.Kind == yaml.MappingNode {
+				v.validate(choice, choicesType, variationKey+"."+choicesFromProperty)
+				continue
+			}
+			v.validate(choice, contextType, variationKey+"."+variation.Content[j].Value)
+		}
+		v.validateDefault(variation, variationKey)
+	}
+}
+
+// validateDefault reports default choices of given variation node that are not among its choices, unless
+// choices may also be declared elsewhere (ie: in parent files, extended contexts or generated dynamically)
+func (v *validator) validateDefault(variation *yaml.Node, key string) {
+	if v.inherits || v.extending > 0 {
+		return
+	}
+	var defaultNode *yaml.Node
+	multi := false
+	var choices []string
+	for j := 0; j+1 < len(variation.Content); j += 2 {
+		name, value := variation.Content[j].Value, variation.Content[j+1]
+		switch {
+		case isUnsetNode(value):
+		case name == defaultProperty && value.Kind == yaml.ScalarNode:
+			defaultNode = value
+		case name == multiProperty && value.Kind == yaml.ScalarNode:
+			value.Decode(&multi)
+		case name == choicesFromProperty && value.Kind == yaml.MappingNode:
+			return
+		default:
+			choices = append(choices, name)
+		}
+	}
+	if defaultNode == nil {
+		return
+	}
+
+	names := []string{defaultNode.Value}
+	if multi {
+		names = strings.Split(defaultNode.Value, MultiSeparator)
+	}
+	for _, name := range names {
+		if !stringIsInStrings(name, choices) {
+			v.report(defaultNode, "unknown default choice %q for %q (expecting one of: %s)", name, key, strings.Join(choices, ", "))
+		}
+	}
+}
+
+// suggestField returns a hint about the known field closest to given unknown name, if any
+func suggestField(name string, fields []yamlField) string {
+	lower := strings.ToLower(name)
+	var candidates []string
+	for _, field := range fields {
+		fieldName := strings.ToLower(field.Name)
+		if strings.HasPrefix(fieldName, lower) || strings.HasPrefix(lower, fieldName) {
+			candidates = append(candidates, field.Name)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return fmt.Sprintf(" (did you mean %q?)", candidates[0])
+}
+
+// validateContextFileNode checks given (migrated) context file document node against the
+// ContextFile structure and returns all problems found
+func validateContextFileNode(path string, doc *yaml.Node) Diagnostics {
+	if path == "" {
+		path = "<context file>"
+	}
+	v := validator{path: path}
+	root := doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil
+		}
+		root = root.Content[0]
+	}
+	if root.Kind == 0 {
+		return nil
+	}
+	if parents, _ := getMappingEntry(root, "parents"); parents != nil {
+		v.inherits = true
+	}
+	if _, cascade := getMappingEntry(root, "cascade"); cascade != nil && cascade.Value == "true" {
+		v.inherits = true
+	}
+	v.validate(root, reflect.TypeOf(ContextFile{}), "")
+	return v.diagnostics
+}
+
+// ValidateContextFile checks the structure of context file at given path and returns all problems
+// found, with their precise locations
+func ValidateContextFile(path string) (Diagnostics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read context file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode context file %q: %w", path, err)
+	}
+	if _, err := migrate(&doc); err != nil {
+		return nil, fmt.Errorf("failed to migrate context file %q: %w", path, err)
+	}
+	resolveMergeTags(&doc)
+
+	return validateContextFileNode(path, &doc), nil
+}
@@ -0,0 +1,120 @@
+package yey
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateContextFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, yeyRCFileName, `image: alpine
+mount:
+  ~/: /home
+env:
+  - FOO=bar
+remove: maybe
+variations:
+  env:
+    dev:
+      imag: dev_image
+    stg: !unset
+  region: us-east1
+`)
+
+	diagnostics, err := ValidateContextFile(path)
+	require.NoError(t, err)
+
+	var actual []string
+	for _, diagnostic := range diagnostics {
+		actual = append(actual, diagnostic.String())
+	}
+	assert.Equal(t, []string{
+		path + `:2:1: unknown field "mount" (did you mean "mounts"?)`,
+		path + `:5:3: expecting map for "env"`,
+		path + `:6:9: expecting boolean for "remove"`,
+		path + `:10:7: unknown field "variations.env.dev.imag" (did you mean "image"?)`,
+		path + `:12:11: expecting map of choices for variation "variations.region"`,
+	}, actual)
+}
+
+func TestValidateVariationDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, yeyRCFileName, `variations:
+  env:
+    default: prd
+    dev: {}
+    prod: {}
+  cloud:
+    multi: true
+    default: aws+gpc
+    aws: {}
+    gcp: {}
+  cluster:
+    default: staging
+    choicesFrom:
+      command: kubectl config get-contexts -o name
+  lang:
+    default: go
+    go: {}
+templates:
+  base:
+    extends: env/dev
+    variations:
+      env:
+        default: qa
+`)
+
+	diagnostics, err := ValidateContextFile(path)
+	require.NoError(t, err)
+
+	var actual []string
+	for _, diagnostic := range diagnostics {
+		actual = append(actual, diagnostic.String())
+	}
+	assert.Equal(t, []string{
+		path + `:3:14: unknown default choice "prd" for "variations.env" (expecting one of: dev, prod)`,
+		path + `:8:14: unknown default choice "gpc" for "variations.cloud" (expecting one of: aws, gcp)`,
+	}, actual)
+
+	// Choices may be declared by parents
+	parent := writeFile(t, dir, "parent.yaml", "variations:\n  env:\n    prod: {}\n")
+	contexts, err := parseContextFile(filepath.Join(dir, "child.yaml"), []byte("parent: "+parent+"\nvariations:\n  env:\n    default: prod\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", contexts.Variations[0].Default)
+}
+
+func TestParseContextFileWarnsAboutUnknownFields(t *testing.T) {
+	contexts, err := parseContextFile("/project/.yeyrc.yaml", []byte("image: alpine\nmount:\n  ~/: /home\n"), nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "alpine", contexts.Image)
+}
+
+func TestParseContextFileRejectsUnknownFieldsWhenStrict(t *testing.T) {
+	IsStrict = true
+	defer func() { IsStrict = false }()
+
+	_, err := parseContextFile("/project/.yeyrc.yaml", []byte("mount:\n  ~/: /home\n"), nil, true)
+	assert.EqualError(t, err, "invalid context file:\n/project/.yeyrc.yaml:1:1: unknown field \"mount\" (did you mean \"mounts\"?)")
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := JSONSchema()
+	require.NoError(t, err)
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	properties := schema["properties"].(map[string]interface{})
+	assert.Contains(t, properties, "parents")
+	assert.Contains(t, properties, "mounts")
+	assert.Contains(t, properties, "dockerArgs")
+	assert.NotContains(t, properties, "path")
+
+	context := schema["definitions"].(map[string]interface{})["context"].(map[string]interface{})
+	assert.Equal(t, false, context["additionalProperties"])
+	assert.Contains(t, context["properties"], "variations")
+}
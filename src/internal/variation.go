@@ -1,13 +1,53 @@
 package yey
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultProperty is the reserved variation key for specifying default choice
+	defaultProperty = "default"
+
+	// multiProperty is the reserved variation key for allowing multiple choices to be selected
+	multiProperty = "multi"
+
+	// MultiSeparator separates the choices selected together in multi-select variations (ie: `aws+gcp`)
+	MultiSeparator = "+"
+)
+
 type Variation struct {
 	Name     string
 	Contexts map[string]Context
+
+	// Names is the list of context names in declaration order
+	Names []string `yaml:"-"`
+
+	// Default is the name of the context to use when not prompting user
+	Default string `yaml:",omitempty"`
+
+	// Multi indicates that multiple choices can be selected together and merged in declaration order
+	Multi bool `yaml:",omitempty"`
+
+	// ChoicesFrom optionally defines how to generate additional contexts dynamically
+	ChoicesFrom *ChoicesFrom `yaml:",omitempty"`
+
+	// unset indicates that this variation was marked with `!unset` to remove it
+	unset bool
+
+	// multiUnset indicates that multi was marked with `!unset` to disable inherited multi-selection
+	multiUnset bool
+
+	// choicesFromUnset indicates that choicesFrom was marked with `!unset` to remove inherited one
+	choicesFromUnset bool
 }
 
 // Clone returns a deep-copy of this variation
 func (l Variation) Clone() Variation {
 	clone := l
+	clone.Names = append([]string(nil), l.Names...)
+	clone.ChoicesFrom = l.ChoicesFrom.Clone()
 	clone.Contexts = make(map[string]Context, len(l.Contexts))
 	for key, value := range l.Contexts {
 		clone.Contexts[key] = value.Clone()
@@ -17,16 +57,50 @@ func (l Variation) Clone() Variation {
 
 // Merge creates a deep-copy of this variation and copies values from given source variation on top of it
 func (l Variation) Merge(source Variation) Variation {
+	// Unset variations replace, or get replaced by, other variation altogether
+	if source.unset || l.unset {
+		return source.Clone()
+	}
 	merged := Variation{
-		Name:     l.Name,
-		Contexts: make(map[string]Context),
+		Name:        l.Name,
+		Contexts:    make(map[string]Context),
+		Names:       l.GetNames(),
+		Default:     l.Default,
+		Multi:       l.Multi,
+		ChoicesFrom: l.ChoicesFrom.Clone(),
+
+		multiUnset:       l.multiUnset,
+		choicesFromUnset: l.choicesFromUnset,
+	}
+	if source.Default != "" {
+		merged.Default = source.Default
+	}
+	if source.Multi {
+		merged.Multi = true
+		merged.multiUnset = false
+	} else if source.multiUnset {
+		merged.Multi = false
+		merged.multiUnset = true
+	}
+	if source.ChoicesFrom != nil {
+		merged.ChoicesFrom = source.ChoicesFrom.Clone()
+		merged.choicesFromUnset = false
+	} else if source.choicesFromUnset {
+		merged.ChoicesFrom = nil
+		merged.choicesFromUnset = true
 	}
 	for key, value := range l.Contexts {
 		merged.Contexts[key] = value.Clone()
 	}
-	for key, value := range source.Contexts {
+	for _, key := range source.GetNames() {
+		value := source.Contexts[key]
+		if _, ok := merged.Contexts[key]; !ok {
+			merged.Names = append(merged.Names, key)
+		}
+
+		// Unset contexts replace, or get replaced by, other context altogether
 		existing, ok := merged.Contexts[key]
-		if ok {
+		if ok && !existing.unset && !value.unset {
 			merged.Contexts[key] = existing.Merge(value, true)
 		} else {
 			merged.Contexts[key] = value
@@ -34,3 +108,64 @@ func (l Variation) Merge(source Variation) Variation {
 	}
 	return merged
 }
+
+// GetNames returns the names of this variation's contexts, in declaration order, followed by
+// those with unknown order (sorted alphabetically)
+func (l Variation) GetNames() []string {
+	names := make([]string, 0, len(l.Contexts))
+	for _, name := range l.Names {
+		if _, ok := l.Contexts[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var others []string
+	for name := range l.Contexts {
+		if !stringIsInStrings(name, names) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// ParseSelection returns the names of the choices selected by given name, in declaration order.
+// For multi-select variations, name may combine multiple choices (ie: `aws+gcp`).
+func (l Variation) ParseSelection(name string) ([]string, error) {
+	if !l.Multi {
+		if _, ok := l.Contexts[name]; !ok {
+			return nil, fmt.Errorf("context %q not found in variation %q", name, l.Name)
+		}
+		return []string{name}, nil
+	}
+
+	parts := strings.Split(name, MultiSeparator)
+	for i, part := range parts {
+		if _, ok := l.Contexts[part]; !ok {
+			return nil, fmt.Errorf("context %q not found in variation %q", part, l.Name)
+		}
+		if stringIsInStrings(part, parts[:i]) {
+			return nil, fmt.Errorf("context %q selected multiple times in variation %q", part, l.Name)
+		}
+	}
+
+	var selection []string
+	for _, choice := range l.GetNames() {
+		if stringIsInStrings(choice, parts) {
+			selection = append(selection, choice)
+		}
+	}
+	return selection, nil
+}
+
+// getSelectedContexts returns the contexts selected by given name, in declaration order
+func (l Variation) getSelectedContexts(name string) ([]Context, error) {
+	selection, err := l.ParseSelection(name)
+	if err != nil {
+		return nil, err
+	}
+	contexts := make([]Context, 0, len(selection))
+	for _, choice := range selection {
+		contexts = append(contexts, l.Contexts[choice])
+	}
+	return contexts, nil
+}
@@ -19,21 +19,30 @@ func (l Variations) Clone() Variations {
 
 // GetByName returns variation with given name and whether it was found
 func (l Variations) GetByName(name string) (Variation, bool) {
-	for _, variation := range l {
+	i := l.indexOf(name)
+	if i == -1 {
+		return Variation{}, false
+	}
+	return l[i], true
+}
+
+// indexOf returns index of variation with given name or -1 if not found
+func (l Variations) indexOf(name string) int {
+	for i, variation := range l {
 		if variation.Name == name {
-			return variation, true
+			return i
 		}
 	}
-	return Variation{}, false
+	return -1
 }
 
 // Merge creates a deep-copy of this variation and copies values from given source variation on top of it
 func (l Variations) Merge(source Variations) Variations {
 	merged := l.Clone()
-	for i, variation := range source {
-		existing, ok := merged.GetByName(variation.Name)
-		if ok {
-			merged[i] = existing.Merge(variation)
+	for _, variation := range source {
+		i := merged.indexOf(variation.Name)
+		if i != -1 {
+			merged[i] = merged[i].Merge(variation)
 		} else {
 			merged = append(merged, variation.Clone())
 		}
@@ -42,26 +51,70 @@ func (l Variations) Merge(source Variations) Variations {
 }
 
 func (variations *Variations) UnmarshalYAML(n *yaml.Node) error {
+	resolveMergeTags(n)
 	if n.Kind != yaml.MappingNode {
 		return fmt.Errorf(`expecting map for "variations" property at line %d, column %d`, n.Line, n.Column)
 	}
 	for i := 0; i < len(n.Content); i += 2 {
 		variationName := n.Content[i].Value
 		variationMap := n.Content[i+1]
+		if isUnsetNode(variationMap) {
+			*variations = append(*variations, Variation{
+				Name:     variationName,
+				Contexts: map[string]Context{},
+				unset:    true,
+			})
+			continue
+		}
 		if variationMap.Kind != yaml.MappingNode {
 			return fmt.Errorf(`expecting map for variation item at line %d, column %d`, n.Line, n.Column)
 		}
-		var contexts map[string]Context
-		if err := variationMap.Decode(&contexts); err != nil {
-			return fmt.Errorf("failed to parse contexts for variation %q: %w", variationName, err)
-		}
 		variation := Variation{
 			Name:     variationName,
-			Contexts: contexts,
+			Contexts: make(map[string]Context),
 		}
-		for name, context := range variation.Contexts {
+		for j := 0; j < len(variationMap.Content); j += 2 {
+			name := variationMap.Content[j].Value
+			contextNode := variationMap.Content[j+1]
+			// Reserved keys marked with `!unset` clear what is inherited, rather than unsetting a choice
+			if name == defaultProperty && contextNode.Kind == yaml.ScalarNode {
+				variation.Default = contextNode.Value
+				continue
+			}
+			if name == multiProperty && isUnsetNode(contextNode) {
+				variation.multiUnset = true
+				continue
+			}
+			if name == choicesFromProperty && isUnsetNode(contextNode) {
+				variation.choicesFromUnset = true
+				continue
+			}
+			if name == multiProperty && contextNode.Kind == yaml.ScalarNode {
+				if err := contextNode.Decode(&variation.Multi); err != nil {
+					return fmt.Errorf("failed to parse %s for variation %q: %w", multiProperty, variationName, err)
+				}
+				continue
+			}
+			if name == choicesFromProperty && contextNode.Kind == yaml.MappingNode {
+				variation.ChoicesFrom = &ChoicesFrom{}
+				if err := contextNode.Decode(variation.ChoicesFrom); err != nil {
+					return fmt.Errorf("failed to parse %s for variation %q: %w", choicesFromProperty, variationName, err)
+				}
+				continue
+			}
+			var context Context
+			if isUnsetNode(contextNode) {
+				context.unset = true
+			} else {
+				removeUnset := takeUnsetEntry(contextNode, removeProperty)
+				if err := contextNode.Decode(&context); err != nil {
+					return fmt.Errorf("failed to parse contexts for variation %q: %w", variationName, err)
+				}
+				context.removeUnset = removeUnset
+			}
 			context.Name = name
 			variation.Contexts[name] = context
+			variation.Names = append(variation.Names, name)
 		}
 		*variations = append(*variations, variation)
 	}
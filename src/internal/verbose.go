@@ -16,3 +16,8 @@ func Log(format string, a ...interface{}) {
 		fmt.Fprintf(os.Stderr, color.Ize(color.Yellow, format+"\n"), a...)
 	}
 }
+
+// Warn outputs a warning message to stderr, regardless of verbosity
+func Warn(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, color.Ize(color.Yellow, "warning: "+format+"\n"), a...)
+}
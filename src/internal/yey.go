@@ -7,6 +7,7 @@ import (
 	"io"
 	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 var (
@@ -24,14 +25,30 @@ func hash(value string) string {
 }
 
 // ContainerName returns the container name to use for given yey rc path
-// and context
+// and context, which includes the values of identity-affecting inputs, if any
 func ContainerName(path string, context Context) string {
-	return fmt.Sprintf(
+	return ContainerNameWithHash(path, context, ConfigHash(context))
+}
+
+// ContainerNameWithHash returns the container name to use for given yey rc path and context, as if
+// its configuration had given hash (ie: the name of a container created before configuration changed)
+func ContainerNameWithHash(path string, context Context, configHash string) string {
+	name := fmt.Sprintf(
 		"%s-%s-%s",
 		ContainerPathPrefix(path),
 		sanitizeContextName(context.Name),
-		hash(context.String()),
+		configHash,
 	)
+	if identityInputs := context.getIdentityInputs(); len(identityInputs) > 0 {
+		name += "-" + hash(strings.Join(identityInputs, "\n"))
+	}
+	return name
+}
+
+// ConfigHash returns the hash of given context's configuration, regardless of the values of its
+// identity-affecting inputs
+func ConfigHash(context Context) string {
+	return hash(context.String())
 }
 
 func sanitizeContextName(value string) string {
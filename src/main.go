@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 
 	"github.com/silphid/yey/src/cmd"
+	"github.com/silphid/yey/src/cmd/config"
+	"github.com/silphid/yey/src/cmd/config/migrate"
+	"github.com/silphid/yey/src/cmd/config/schema"
+	"github.com/silphid/yey/src/cmd/config/validate"
 
 	"github.com/silphid/yey/src/cmd/get"
 	"github.com/silphid/yey/src/cmd/pull"
@@ -14,10 +20,14 @@ import (
 	getcontainers "github.com/silphid/yey/src/cmd/get/containers"
 	getcontext "github.com/silphid/yey/src/cmd/get/context"
 	getcontexts "github.com/silphid/yey/src/cmd/get/contexts"
+	getpresets "github.com/silphid/yey/src/cmd/get/presets"
+	"github.com/silphid/yey/src/cmd/history"
 	"github.com/silphid/yey/src/cmd/tidy"
 
 	"github.com/silphid/yey/src/cmd/run"
 	"github.com/silphid/yey/src/cmd/versioning"
+	yey "github.com/silphid/yey/src/internal"
+	"github.com/silphid/yey/src/internal/docker"
 )
 
 var version string
@@ -31,21 +41,43 @@ func main() {
 		stop()
 	}()
 
+	yey.Version = version
+
 	rootCmd := cmd.NewRoot()
 	rootCmd.AddCommand(run.New())
 	rootCmd.AddCommand(versioning.New(version))
 	rootCmd.AddCommand(tidy.New())
 	rootCmd.AddCommand(remove.New())
 	rootCmd.AddCommand(pull.New())
+	rootCmd.AddCommand(history.New())
 
 	getCmd := get.New()
 	getCmd.AddCommand(getcontext.New())
 	getCmd.AddCommand(getcontexts.New())
 	getCmd.AddCommand(getcontainers.New())
+	getCmd.AddCommand(getpresets.New())
 
 	rootCmd.AddCommand(getCmd)
 
+	configCmd := config.New()
+	configCmd.AddCommand(migrate.New())
+	configCmd.AddCommand(validate.New())
+	configCmd.AddCommand(schema.New())
+
+	rootCmd.AddCommand(configCmd)
+
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		os.Exit(-1)
+		os.Exit(getExitCode(err))
+	}
+}
+
+// getExitCode returns the exit code of container process that failed with given error, if any,
+// otherwise reporting error and returning a generic exit code
+func getExitCode(err error) int {
+	var exitErr *docker.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
 	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	return 1
 }
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/silphid/yey/src/internal/docker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExitCode(t *testing.T) {
+	assert.Equal(t, 3, getExitCode(&docker.ExitError{Code: 3}))
+	assert.Equal(t, 2, getExitCode(fmt.Errorf("wrapped: %w", &docker.ExitError{Code: 2})))
+	assert.Equal(t, 1, getExitCode(errors.New("failed")))
+}